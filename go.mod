@@ -2,7 +2,10 @@ module github.com/56quarters/mediarename
 
 go 1.24
 
-require github.com/alecthomas/kingpin/v2 v2.4.0
+require (
+	github.com/alecthomas/kingpin/v2 v2.4.0
+	golang.org/x/text v0.28.0
+)
 
 require (
 	github.com/alecthomas/units v0.0.0-20240927000941-0f3dac36c52b // indirect
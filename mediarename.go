@@ -1,9 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/alecthomas/kingpin/v2"
@@ -12,16 +15,21 @@ import (
 )
 
 const (
-	apiBase = "https://api.tvmaze.com/"
+	apiBase  = "https://api.tvmaze.com/"
+	tmdbBase = "https://api.themoviedb.org/"
 )
 
 var (
 	extensions = map[string]struct{}{
+		".ass": {},
 		".avi": {},
 		".idx": {},
 		".mp4": {},
 		".mkv": {},
+		".srt": {},
+		".ssa": {},
 		".sub": {},
+		".vtt": {},
 	}
 )
 
@@ -38,6 +46,62 @@ func realMain() int {
 	tvSrc := tv.Arg("src", "Directory of files to rename").Required().String()
 	tvDest := tv.Arg("dest", "Destination of renamed files").Required().String()
 	tvCommit := tv.Flag("commit", "Actually rename things instead of just printing new names.").Default("false").Bool()
+	tvMode := tv.Flag("mode", "How to place files at their new names.").Default(string(mediarename.ModeMove)).Enum(mediarename.Modes()...)
+	tvOnConflict := tv.Flag("on-conflict", "What to do when a new name is already in use.").Default(string(mediarename.ConflictSkip)).Enum(mediarename.ConflictPolicies()...)
+	tvFallback := tv.Flag("fallback", "Mode to use when the requested mode is not possible between filesystems or not supported.").Default("none").Enum(append([]string{"none"}, mediarename.FallbackModes()...)...)
+	tvRelativeLinks := tv.Flag("relative-links", "Create symbolic links with relative instead of absolute targets.").Default("false").Bool()
+	tvPruneLinks := tv.Flag("prune-links", "Remove symbolic links in the destination whose targets no longer exist.").Default("false").Bool()
+	tvVerify := tv.Flag("verify", "Verify the size and checksum of copied files.").Default("false").Bool()
+	tvPreserve := tv.Flag("preserve", "Attributes of originals to keep on copies, may be repeated.").Default(string(mediarename.AttrMode), string(mediarename.AttrTimes)).Enums(mediarename.Attributes()...)
+	tvDirMode := tv.Flag("dir-mode", "Octal permissions of created directories, e.g. 2775. Defaults to 0755 less the umask.").String()
+	tvOwner := tv.Flag("owner", "User name or ID to give placed files and created directories.").String()
+	tvGroup := tv.Flag("group", "Group name or ID to give placed files and created directories.").String()
+	tvWorkers := tv.Flag("workers", "Number of files to copy in parallel.").Default("4").Int()
+	tvBandwidth := tv.Flag("bwlimit", "Maximum bytes per second to write when copying files, e.g. 20MiB.").Bytes()
+	tvAtomic := tv.Flag("atomic", "Roll back every completed rename if any rename fails.").Default("false").Bool()
+	tvJournal := tv.Flag("journal", "Write a journal of committed renames that can be undone.").Default("true").Bool()
+	tvStateDir := tv.Flag("state-dir", "Directory to write journals to.").Default(mediarename.DefaultStateDir()).String()
+	tvConfig := tv.Flag("config", "JSON config file to read the default template from.").PlaceHolder(mediarename.DefaultConfigPath()).String()
+	tvTemplate := tv.Flag("template", "Go text/template for new file names, relative to dest and without an extension. Overrides the config file.").PlaceHolder(mediarename.DefaultTemplate).Envar("MEDIARENAME_TEMPLATE").String()
+	tvTemplateFile := tv.Flag("template-file", "File containing a template for new file names, overrides --template.").ExistingFile()
+	tvPreset := tv.Flag("preset", "Name files following the conventions of a media server, overrides --template and the config file. Can't be used with --template-file.").Enum(mediarename.Presets()...)
+	tvProfile := tv.Flag("fs-profile", "Make names valid on this kind of filesystem.").Default(string(mediarename.ProfilePosix)).Enum(mediarename.Profiles()...)
+	tvSeparator := tv.Flag("separator", "Separator used in place of spaces by the sanitize template function.").Default("_").String()
+	tvPreserveCase := tv.Flag("preserve-case", "Don't lowercase names in the sanitize template function.").Default("false").Bool()
+	tvASCII := tv.Flag("ascii", "Transliterate names to ASCII, keeping letters of scripts that can't be transliterated.").Default("false").Bool()
+	tvNFC := tv.Flag("nfc", "Normalize names to Unicode normalization form C.").Default("false").Bool()
+	tvMaxName := tv.Flag("max-name-bytes", "Maximum length of file names in bytes, episode titles are shortened to fit.").Default("255").Int()
+	tvMaxPath := tv.Flag("max-path-bytes", "Maximum length of full paths in bytes, episode titles are shortened to fit.").Default("4096").Int()
+	tvNFO := tv.Flag("nfo", "Write NFO metadata files for Kodi, Jellyfin, and Emby next to renamed files.").Default("false").Bool()
+	tvCleanup := tv.Flag("cleanup", "Remove source directories left empty after renaming succeeds.").Default("false").Bool()
+	tvCleanupJunk := tv.Flag("cleanup-junk", "Also delete files matching --junk from source directories that are otherwise empty.").Default("false").Bool()
+	tvJunk := tv.Flag("junk", "Pattern for leftover files and directories to delete with --cleanup-junk, may be repeated.").Default(mediarename.DefaultJunkPatterns()...).Strings()
+	tvExtras := tv.Flag("extras", "Place featurettes, deleted scenes, and trailers without an episode number in extras folders.").Default("true").Bool()
+	tvKeepSamples := tv.Flag("keep-samples", "Rename files that look like samples instead of excluding them.").Default("false").Bool()
+	tvSampleRatio := tv.Flag("sample-ratio", "Exclude videos smaller than this fraction of the median video size as samples, negative to disable.").Default("0.1").Float64()
+	tvArtwork := tv.Flag("artwork", "Download show and season posters and episode thumbnails next to renamed files.").Default("false").Bool()
+	tvInteractive := tv.Flag("interactive", "Review each rename before it is made: accept, skip, edit the new name, or pick a different episode.").Default("false").Bool()
+	tvPlan := tv.Flag("plan", "Write the planned renames to this file, for review or to run later with the apply command.").String()
+	tvPlanFormat := tv.Flag("plan-format", "Format of the file written by --plan. Only json plans can be applied.").Default(string(mediarename.PlanJSON)).Enum(mediarename.PlanFormats()...)
+	tvPreview := tv.Flag("preview", "How to show planned renames: a log line per file or the destination as a directory tree.").Default(string(mediarename.PreviewLog)).Enum(mediarename.Previews()...)
+	tvLanguage := tv.Flag("language", "Preferred language for show and episode titles, e.g. 'de' or 'pt-BR'. Episode titles need --tmdb-api-key. Falls back to the original title.").Default("").String()
+	tvTmdbKey := tv.Flag("tmdb-api-key", "TMDB API key used to translate episode titles for --language and find episode IDs for --nfo.").Envar("TMDB_API_KEY").String()
+
+	apply := kp.Command("apply", "rename files following a plan written by the tv command")
+	applyPlan := apply.Arg("plan", "JSON plan to apply").Required().ExistingFile()
+	applyCommit := apply.Flag("commit", "Actually rename things instead of just printing what would be renamed.").Default("false").Bool()
+	applyFallback := apply.Flag("fallback", "Mode to use when the planned mode is not possible between filesystems or not supported.").Default("none").Enum(append([]string{"none"}, mediarename.FallbackModes()...)...)
+	applyVerify := apply.Flag("verify", "Verify the size and checksum of copied files.").Default("false").Bool()
+	applyWorkers := apply.Flag("workers", "Number of files to copy in parallel.").Default("4").Int()
+	applyBandwidth := apply.Flag("bwlimit", "Maximum bytes per second to write when copying files, e.g. 20MiB.").Bytes()
+	applyAtomic := apply.Flag("atomic", "Roll back every completed rename if any rename fails.").Default("false").Bool()
+	applyJournal := apply.Flag("journal", "Write a journal of committed renames that can be undone.").Default("true").Bool()
+	applyStateDir := apply.Flag("state-dir", "Directory to write journals to.").Default(mediarename.DefaultStateDir()).String()
+
+	undo := kp.Command("undo", "undo renames recorded in a journal")
+	undoJournal := undo.Arg("journal", "Journal to undo, the most recent journal if not set").String()
+	undoStateDir := undo.Flag("state-dir", "Directory to find the most recent journal in.").Default(mediarename.DefaultStateDir()).String()
+	undoCommit := undo.Flag("commit", "Actually undo things instead of just printing what would be undone.").Default("false").Bool()
 
 	command, err := kp.Parse(os.Args[1:])
 	if err != nil {
@@ -47,24 +111,228 @@ func realMain() int {
 
 	switch command {
 	case tv.FullCommand():
-		if err := renameTv(*tvSrc, *tvDest, *tvID, *tvCommit, logger); err != nil {
+		fallback := mediarename.Mode(*tvFallback)
+		if *tvFallback == "none" {
+			fallback = ""
+		}
+
+		configPath := *tvConfig
+		if configPath == "" {
+			configPath = mediarename.DefaultConfigPath()
+		}
+
+		config, err := mediarename.ReadConfig(configPath, *tvConfig == "")
+		if err != nil {
+			logger.Error("failed to read config", "err", err)
+			return 1
+		}
+
+		if *tvPreset != "" && *tvTemplateFile != "" {
+			logger.Error("--preset and --template-file cannot be used together")
+			return 1
+		}
+
+		templateText := *tvTemplate
+		if templateText == "" {
+			templateText = config.Template
+		}
+
+		if templateText == "" {
+			templateText = mediarename.DefaultTemplate
+		}
+
+		if *tvTemplateFile != "" {
+			b, err := os.ReadFile(*tvTemplateFile)
+			if err != nil {
+				logger.Error("failed to read template file", "err", err)
+				return 1
+			}
+
+			templateText = strings.TrimSpace(string(b))
+		}
+
+		var template *mediarename.NameTemplate
+		if *tvPreset != "" {
+			template, err = mediarename.PresetTemplate(*tvPreset)
+		} else {
+			template, err = mediarename.ParseNameTemplate(templateText)
+		}
+
+		if err != nil {
+			logger.Error("failed to parse template", "err", err)
+			return 1
+		}
+
+		sanitizer := mediarename.Sanitizer{
+			Profile:      mediarename.Profile(*tvProfile),
+			Separator:    *tvSeparator,
+			PreserveCase: *tvPreserveCase,
+			ASCII:        *tvASCII,
+			NFC:          *tvNFC,
+		}
+
+		preserve, err := mediarename.ParsePreserve(*tvPreserve)
+		if err != nil {
+			logger.Error("failed to parse attributes to preserve", "err", err)
+			return 1
+		}
+
+		var dirMode os.FileMode
+		if *tvDirMode != "" {
+			mode, err := strconv.ParseUint(*tvDirMode, 8, 32)
+			if err != nil {
+				logger.Error("failed to parse directory mode", "err", err)
+				return 1
+			}
+
+			dirMode = fileMode(mode)
+		}
+
+		opts := mediarename.TvOptions{
+			Commit:         *tvCommit,
+			Language:       mediarename.ParseLanguage(*tvLanguage),
+			Template:       template,
+			Sanitizer:      &sanitizer,
+			Limits:         &mediarename.Limits{MaxName: *tvMaxName, MaxPath: *tvMaxPath},
+			KeepSamples:    *tvKeepSamples,
+			SampleRatio:    *tvSampleRatio,
+			Extras:         *tvExtras,
+			Mode:           mediarename.Mode(*tvMode),
+			Fallback:       fallback,
+			Verify:         *tvVerify,
+			Preserve:       &preserve,
+			DirMode:        dirMode,
+			RelativeLinks:  *tvRelativeLinks,
+			OnConflict:     mediarename.ConflictPolicy(*tvOnConflict),
+			Atomic:         *tvAtomic,
+			Workers:        *tvWorkers,
+			BandwidthLimit: int64(*tvBandwidth),
+			Progress:       os.Stderr,
+		}
+
+		if *tvJournal {
+			opts.JournalDir = *tvStateDir
+		}
+
+		if *tvTmdbKey != "" && (*tvLanguage != "" || *tvNFO) {
+			httpClient := &http.Client{Timeout: 10 * time.Second}
+			tmdb, err := mediarename.NewTmdbClient(tmdbBase, *tvTmdbKey, httpClient, logger)
+			if err != nil {
+				logger.Error("failed to create TMDB client", "err", err)
+				return 1
+			}
+
+			if *tvLanguage != "" {
+				opts.Translator = tmdb
+			}
+
+			opts.EpisodeIDs = tmdb
+		}
+
+		if *tvOwner != "" || *tvGroup != "" {
+			owner, err := mediarename.LookupOwner(*tvOwner, *tvGroup)
+			if err != nil {
+				logger.Error("failed to lookup owner", "err", err)
+				return 1
+			}
+
+			opts.Owner = owner
+		}
+
+		steps := tvSteps{
+			interactive: *tvInteractive,
+			plan:        *tvPlan,
+			planFormat:  mediarename.PlanFormat(*tvPlanFormat),
+			preview:     mediarename.Preview(*tvPreview),
+			pruneLinks:  *tvPruneLinks,
+			nfo:         *tvNFO,
+			artwork:     *tvArtwork,
+			cleanup:     *tvCleanup || *tvCleanupJunk,
+		}
+
+		if *tvCleanupJunk {
+			steps.junk = *tvJunk
+		}
+
+		if err := renameTv(*tvSrc, *tvDest, *tvID, steps, opts, logger); err != nil {
 			logger.Error("failed to rename tv episodes", "err", err)
 			return 1
 		}
+	case apply.FullCommand():
+		fallback := mediarename.Mode(*applyFallback)
+		if *applyFallback == "none" {
+			fallback = ""
+		}
+
+		opts := mediarename.TvOptions{
+			Commit:         *applyCommit,
+			Fallback:       fallback,
+			Verify:         *applyVerify,
+			Atomic:         *applyAtomic,
+			Workers:        *applyWorkers,
+			BandwidthLimit: int64(*applyBandwidth),
+			Progress:       os.Stderr,
+		}
+
+		if *applyJournal {
+			opts.JournalDir = *applyStateDir
+		}
+
+		if err := applyPlanFile(*applyPlan, opts, logger); err != nil {
+			logger.Error("failed to apply plan", "err", err)
+			return 1
+		}
+	case undo.FullCommand():
+		if err := undoJournalFile(*undoJournal, *undoStateDir, *undoCommit, logger); err != nil {
+			logger.Error("failed to undo journal", "err", err)
+			return 1
+		}
 	}
 
 	return 0
 }
 
-func renameTv(src string, dest string, showID string, commit bool, logger *slog.Logger) error {
+// fileMode converts octal permissions, including setuid, setgid, and sticky bits, to
+// an os.FileMode.
+func fileMode(mode uint64) os.FileMode {
+	m := os.FileMode(mode & 0777)
+	if mode&04000 != 0 {
+		m |= os.ModeSetuid
+	}
+
+	if mode&02000 != 0 {
+		m |= os.ModeSetgid
+	}
+
+	if mode&01000 != 0 {
+		m |= os.ModeSticky
+	}
+
+	return m
+}
+
+// tvSteps are optional steps to run before and after renaming files.
+type tvSteps struct {
+	interactive bool
+	plan        string
+	planFormat  mediarename.PlanFormat
+	preview     mediarename.Preview
+	pruneLinks  bool
+	nfo         bool
+	artwork     bool
+	cleanup     bool
+	junk        []string
+}
+
+func renameTv(src string, dest string, showID string, steps tvSteps, opts mediarename.TvOptions, logger *slog.Logger) error {
 	httpClient := &http.Client{Timeout: 10 * time.Second}
 	client, err := mediarename.NewTvMazeClient(apiBase, httpClient, logger)
 	if err != nil {
 		return err
 	}
 
-	renamer := mediarename.NewTvRenamer(client, commit, logger)
-	files, err := renamer.FindFiles(src, extensions)
+	renamer := mediarename.NewTvRenamer(client, opts, logger)
+	files, samples, err := renamer.FindFiles(src, extensions)
 	if err != nil {
 		return err
 	}
@@ -74,5 +342,127 @@ func renameTv(src string, dest string, showID string, commit bool, logger *slog.
 		return err
 	}
 
-	return renamer.RenameFiles(renames)
+	if steps.interactive {
+		renames, err = renamer.Review(renames, dest, os.Stdin, os.Stderr)
+		if err != nil {
+			return err
+		}
+	}
+
+	if steps.plan != "" {
+		if err := writePlanFile(renamer, renames, steps.plan, steps.planFormat); err != nil {
+			return err
+		}
+
+		logger.Info("wrote plan", "path", steps.plan, "format", steps.planFormat)
+	}
+
+	if steps.preview == mediarename.PreviewTree {
+		if err := mediarename.WriteTree(os.Stdout, dest, files, samples, renames); err != nil {
+			return err
+		}
+	}
+
+	// The tree replaces the log line for each file that a dry run would print
+	if opts.Commit || steps.preview != mediarename.PreviewTree {
+		if err := renamer.RenameFiles(renames); err != nil {
+			return err
+		}
+	}
+
+	if steps.nfo {
+		if err := renamer.WriteNFO(renames, dest); err != nil {
+			return err
+		}
+	}
+
+	if steps.artwork {
+		renamer.DownloadArtwork(renames, dest)
+	}
+
+	if steps.cleanup {
+		if err := renamer.Cleanup(src, renames, steps.junk); err != nil {
+			return err
+		}
+	}
+
+	if steps.pruneLinks {
+		return renamer.RemoveDanglingLinks(dest)
+	}
+
+	return nil
+}
+
+func writePlanFile(renamer *mediarename.TvRenamer, renames []mediarename.Rename, p string, format mediarename.PlanFormat) error {
+	plan, err := renamer.NewPlan(renames)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+
+	if err := mediarename.WritePlan(f, plan, format); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func applyPlanFile(p string, opts mediarename.TvOptions, logger *slog.Logger) error {
+	plan, err := mediarename.ReadPlan(p)
+	if err != nil {
+		return err
+	}
+
+	opts.Mode = plan.Mode
+	opts.RelativeLinks = plan.RelativeLinks
+
+	renamer := mediarename.NewTvRenamer(nil, opts, logger)
+	renames, refused, err := renamer.PlanRenames(plan)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("applying plan", "path", p, "entries", len(plan.Entries))
+	if err := renamer.RenameFiles(renames); err != nil {
+		return err
+	}
+
+	if len(refused) > 0 {
+		return fmt.Errorf("%d entries were skipped because they changed since planning or their new names are in use", len(refused))
+	}
+
+	return nil
+}
+
+func undoJournalFile(journal string, stateDir string, commit bool, logger *slog.Logger) error {
+	if journal == "" {
+		latest, err := mediarename.LatestJournal(stateDir)
+		if err != nil {
+			return err
+		}
+
+		journal = latest
+	}
+
+	entries, err := mediarename.ReadJournal(journal)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("undoing journal", "path", journal, "entries", len(entries))
+	skipped, err := mediarename.NewUndoer(commit, logger).Undo(entries)
+	if err != nil {
+		return err
+	}
+
+	if len(skipped) > 0 {
+		logger.Warn("some files were skipped because they changed", "skipped", len(skipped))
+	}
+
+	return nil
 }
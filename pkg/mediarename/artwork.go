@@ -0,0 +1,136 @@
+package mediarename
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// DownloadArtwork downloads artwork for renamed files using the names media servers look
+// for: "poster.jpg" and a poster for each season such as "season01-poster.jpg" in the show
+// directory, and a thumbnail next to each file such as "the_show-s01e01-pilot-thumb.jpg".
+// The show directory is the first directory under dest that files were renamed into.
+// Existing artwork is never replaced. Artwork is only logged, not downloaded, unless
+// committing. Images that can't be downloaded are logged and skipped. Does nothing if
+// the client can't provide artwork.
+func (r *TvRenamer) DownloadArtwork(renames []Rename, dest string) {
+	fetcher, ok := r.client.(ArtworkFetcher)
+	if !ok {
+		r.logger.Warn("metadata provider does not support artwork")
+		return
+	}
+
+	var seasons map[int]string
+	seen := make(map[string]struct{})
+
+	for _, op := range renames {
+		if op.Skip || op.Show == nil || len(op.Episodes) == 0 {
+			continue
+		}
+
+		// Subtitles without a video would get a thumbnail named after the subtitle
+		first := op.Episodes[0]
+		thumb := trimExt(op.New) + "-thumb.jpg"
+		if !hasCompanion(op, thumb) && !isCompanion(op.Old) {
+			r.downloadImage(fetcher, thumb, first.Image.URL())
+		}
+
+		showDir, depth := showDir(dest, op.New)
+		if depth == 0 {
+			continue
+		}
+
+		// Every file is from the same show so seasons only need to be looked up once
+		if seasons == nil {
+			seasons = r.seasonImages(fetcher, op.Show)
+		}
+
+		images := []struct{ path, url string }{
+			{path.Join(showDir, "poster.jpg"), op.Show.Image.URL()},
+			{path.Join(showDir, seasonPosterName(first.Season)), seasons[first.Season]},
+		}
+
+		for _, image := range images {
+			if _, ok := seen[image.path]; ok {
+				continue
+			}
+
+			seen[image.path] = struct{}{}
+			r.downloadImage(fetcher, image.path, image.url)
+		}
+	}
+}
+
+// seasonImages returns the URL of the poster for each season of a show, by season number,
+// logging any errors.
+func (r *TvRenamer) seasonImages(fetcher ArtworkFetcher, show *Show) map[int]string {
+	images := make(map[int]string)
+	seasons, err := fetcher.Seasons(show)
+	if err != nil {
+		r.logger.Warn("unable to lookup seasons", "show", show.Name, "err", err)
+		return images
+	}
+
+	for _, s := range seasons {
+		images[s.Number] = s.Image.URL()
+	}
+
+	return images
+}
+
+// downloadImage downloads imageURL to p unless p already exists or there is no image,
+// logging any errors.
+func (r *TvRenamer) downloadImage(fetcher ArtworkFetcher, p string, imageURL string) {
+	if imageURL == "" {
+		r.logger.Debug("no artwork available", "path", p)
+		return
+	}
+
+	if _, err := os.Lstat(p); err == nil {
+		r.logger.Info("artwork already exists", "path", p)
+		return
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		r.logger.Warn("unable to check for existing artwork", "path", p, "err", err)
+		return
+	}
+
+	r.logger.Info("download artwork", "path", p, "url", imageURL)
+	if !r.opts.Commit {
+		return
+	}
+
+	image, err := fetcher.Download(imageURL)
+	if err != nil {
+		r.logger.Warn("unable to download artwork", "path", p, "url", imageURL, "err", err)
+		return
+	}
+
+	if err := r.writeFile(p, image); err != nil {
+		r.logger.Warn("unable to write artwork", "path", p, "err", err)
+	}
+}
+
+// seasonPosterName returns the name of the poster for a season, e.g. "season01-poster.jpg"
+// or "season-specials-poster.jpg" for season zero.
+func seasonPosterName(season int) string {
+	if season == 0 {
+		return "season-specials-poster.jpg"
+	}
+
+	return fmt.Sprintf("season%02d-poster.jpg", season)
+}
+
+// hasCompanion returns true if a file is being renamed along with a companion that will
+// end up at p.
+func hasCompanion(op Rename, p string) bool {
+	for _, c := range op.Companions {
+		if strings.EqualFold(c.New, p) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,127 @@
+package mediarename
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestTvRenamer_DownloadArtwork(t *testing.T) {
+	var mu sync.Mutex
+	downloads := make(map[string]int)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/shows/1/seasons", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `[{"id": 10, "number": 1, "image": {"original": "http://%s/images/season1.jpg"}}]`, r.Host)
+	})
+	mux.HandleFunc("/images/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		downloads[r.URL.Path]++
+		mu.Unlock()
+
+		_, _ = w.Write([]byte("image " + r.URL.Path))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	setup := func(t *testing.T) (string, []Rename, *TvMazeClient) {
+		client, err := NewTvMazeClient(server.URL, server.Client(), slog.New(slog.DiscardHandler))
+		RequireNoError(t, err)
+
+		show := testShow
+		show.Image = &Image{Original: server.URL + "/images/poster.jpg"}
+
+		var episodes Episodes
+		for _, e := range testEpisodes[:2] {
+			e.Image = &Image{Medium: fmt.Sprintf("%s/images/episode%d.jpg", server.URL, e.ID)}
+			episodes = append(episodes, e)
+		}
+
+		dest := filepath.Join(t.TempDir(), "dest")
+		dir := filepath.Join(dest, "the_show", "season_01")
+		return dest, []Rename{
+			{New: filepath.Join(dir, "the_show-s01e01-pilot.mkv"), Show: &show, Episodes: episodes[:1]},
+			{New: filepath.Join(dir, "the_show-s01e02-events.mkv"), Show: &show, Episodes: episodes[1:]},
+		}, client
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		dest, renames, client := setup(t)
+		writeTestFile(t, filepath.Join(dest, "the_show", "season_01", "the_show-s01e02-events-thumb.jpg"), "existing")
+
+		renamer := NewTvRenamer(client, TvOptions{Commit: true}, slog.New(slog.DiscardHandler))
+		renamer.DownloadArtwork(renames, dest)
+
+		RequireEqual(t, "image /images/poster.jpg", readTestFile(t, filepath.Join(dest, "the_show", "poster.jpg")))
+		RequireEqual(t, "image /images/season1.jpg", readTestFile(t, filepath.Join(dest, "the_show", "season01-poster.jpg")))
+		RequireEqual(t, "image /images/episode1.jpg", readTestFile(t, filepath.Join(dest, "the_show", "season_01", "the_show-s01e01-pilot-thumb.jpg")))
+		RequireEqual(t, "existing", readTestFile(t, filepath.Join(dest, "the_show", "season_01", "the_show-s01e02-events-thumb.jpg")))
+
+		mu.Lock()
+		defer mu.Unlock()
+		RequireEqual(t, 1, downloads["/images/poster.jpg"])
+		RequireEqual(t, 0, downloads["/images/episode2.jpg"])
+	})
+
+	t.Run("failed download", func(t *testing.T) {
+		dest, renames, client := setup(t)
+		renames[0].Episodes[0].Image = &Image{Medium: server.URL + "/missing/episode1.jpg"}
+
+		renamer := NewTvRenamer(client, TvOptions{Commit: true}, slog.New(slog.DiscardHandler))
+		renamer.DownloadArtwork(renames, dest)
+
+		_, err := os.Stat(filepath.Join(dest, "the_show", "season_01", "the_show-s01e01-pilot-thumb.jpg"))
+		RequireEqual(t, true, errors.Is(err, os.ErrNotExist))
+		RequireEqual(t, "image /images/poster.jpg", readTestFile(t, filepath.Join(dest, "the_show", "poster.jpg")))
+		RequireEqual(t, "image /images/episode2.jpg", readTestFile(t, filepath.Join(dest, "the_show", "season_01", "the_show-s01e02-events-thumb.jpg")))
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		dest, renames, client := setup(t)
+		renamer := NewTvRenamer(client, TvOptions{}, slog.New(slog.DiscardHandler))
+		renamer.DownloadArtwork(renames, dest)
+
+		_, err := os.Stat(filepath.Join(dest, "the_show", "poster.jpg"))
+		RequireEqual(t, true, errors.Is(err, os.ErrNotExist))
+	})
+
+	t.Run("loose subtitle", func(t *testing.T) {
+		dest, renames, client := setup(t)
+		renames[0].Old = "src/Show.S01E01.en.srt"
+		renames[0].New = filepath.Join(dest, "the_show", "season_01", "the_show-s01e01-pilot.en.srt")
+
+		renamer := NewTvRenamer(client, TvOptions{Commit: true}, slog.New(slog.DiscardHandler))
+		renamer.DownloadArtwork(renames[:1], dest)
+
+		_, err := os.Stat(filepath.Join(dest, "the_show", "season_01", "the_show-s01e01-pilot.en-thumb.jpg"))
+		RequireEqual(t, true, errors.Is(err, os.ErrNotExist))
+		RequireEqual(t, "image /images/poster.jpg", readTestFile(t, filepath.Join(dest, "the_show", "poster.jpg")))
+	})
+}
+
+func TestTvMazeClient_Download(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte("image"))
+	}))
+	defer server.Close()
+
+	client, err := NewTvMazeClient(server.URL, server.Client(), slog.New(slog.DiscardHandler))
+	RequireNoError(t, err)
+
+	for range 3 {
+		image, err := client.Download(server.URL + "/image.jpg")
+		RequireNoError(t, err)
+		RequireEqual(t, "image", string(image))
+	}
+
+	RequireEqual(t, 1, requests)
+}
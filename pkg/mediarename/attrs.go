@@ -0,0 +1,168 @@
+package mediarename
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// Attribute is a file attribute that can be preserved when a file is copied.
+type Attribute string
+
+const (
+	// AttrMode is the permission bits of a file.
+	AttrMode Attribute = "mode"
+	// AttrTimes is the access and modification times of a file.
+	AttrTimes Attribute = "times"
+	// AttrOwner is the user and group that own a file. Changing the user usually
+	// requires running as root.
+	AttrOwner Attribute = "owner"
+	// AttrXattrs is the extended attributes of a file in the "user" namespace.
+	AttrXattrs Attribute = "xattrs"
+)
+
+// Attributes returns the names of all attributes that can be preserved.
+func Attributes() []string {
+	return []string{string(AttrMode), string(AttrTimes), string(AttrOwner), string(AttrXattrs)}
+}
+
+// Preserve is the set of attributes of the original file to carry over to copies,
+// including files copied by a move between filesystems. Renamed and hard linked files
+// always keep all of their attributes since they are the same file.
+type Preserve struct {
+	Mode   bool
+	Times  bool
+	Owner  bool
+	Xattrs bool
+}
+
+// DefaultPreserve returns a Preserve that keeps the permissions and times of files.
+func DefaultPreserve() Preserve {
+	return Preserve{Mode: true, Times: true}
+}
+
+// ParsePreserve returns a Preserve that keeps each of the named attributes.
+func ParsePreserve(names []string) (Preserve, error) {
+	var p Preserve
+	for _, name := range names {
+		switch Attribute(name) {
+		case AttrMode:
+			p.Mode = true
+		case AttrTimes:
+			p.Times = true
+		case AttrOwner:
+			p.Owner = true
+		case AttrXattrs:
+			p.Xattrs = true
+		default:
+			return Preserve{}, fmt.Errorf("unknown attribute %s", name)
+		}
+	}
+
+	return p, nil
+}
+
+// preserveAttributes copies the selected attributes from src, described by info, to the
+// file at p. Ownership is changed first since it may clear setuid and setgid bits and
+// times are changed last since changing anything else may update them.
+func preserveAttributes(src string, p string, info os.FileInfo, preserve Preserve) error {
+	if preserve.Owner {
+		if err := preserveOwner(p, info); err != nil {
+			return fmt.Errorf("unable to preserve owner: %w", err)
+		}
+	}
+
+	if preserve.Xattrs {
+		if err := copyXattrs(src, p); err != nil {
+			return fmt.Errorf("unable to preserve extended attributes: %w", err)
+		}
+	}
+
+	// Setuid and setgid bits are kept along with the permissions
+	if preserve.Mode {
+		if err := os.Chmod(p, info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+			return err
+		}
+	}
+
+	if preserve.Times {
+		return os.Chtimes(p, fileAtime(info), info.ModTime())
+	}
+
+	return nil
+}
+
+// Owner is the user and group to give to files placed in the destination and the
+// directories created for them. A UID or GID of -1 leaves it unchanged.
+type Owner struct {
+	UID int
+	GID int
+}
+
+// LookupOwner returns the Owner for a user and group given by name or numeric ID. An
+// empty user or group is left unchanged.
+func LookupOwner(username string, group string) (*Owner, error) {
+	owner := Owner{UID: -1, GID: -1}
+	if username != "" {
+		uid, err := strconv.Atoi(username)
+		if err != nil {
+			u, err := user.Lookup(username)
+			if err != nil {
+				return nil, fmt.Errorf("unable to lookup user %s: %w", username, err)
+			}
+
+			if uid, err = strconv.Atoi(u.Uid); err != nil {
+				return nil, fmt.Errorf("unable to parse ID of user %s: %w", username, err)
+			}
+		}
+
+		owner.UID = uid
+	}
+
+	if group != "" {
+		gid, err := strconv.Atoi(group)
+		if err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return nil, fmt.Errorf("unable to lookup group %s: %w", group, err)
+			}
+
+			if gid, err = strconv.Atoi(g.Gid); err != nil {
+				return nil, fmt.Errorf("unable to parse ID of group %s: %w", group, err)
+			}
+		}
+
+		owner.GID = gid
+	}
+
+	return &owner, nil
+}
+
+// makeDirs creates dir and any missing parents with perm, before the umask, and returns
+// each directory that was created, parents first.
+func makeDirs(dir string, perm os.FileMode) ([]string, error) {
+	var missing []string
+	for p := dir; ; p = filepath.Dir(p) {
+		if _, err := os.Stat(p); err == nil {
+			break
+		}
+
+		missing = append(missing, p)
+		if p == filepath.Dir(p) {
+			break
+		}
+	}
+
+	if err := os.MkdirAll(dir, perm); err != nil {
+		return nil, err
+	}
+
+	created := make([]string, 0, len(missing))
+	for i := len(missing) - 1; i >= 0; i-- {
+		created = append(created, missing[i])
+	}
+
+	return created, nil
+}
@@ -0,0 +1,49 @@
+package mediarename
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestCopyFileXattrs(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.mkv")
+	dst := filepath.Join(dir, "dst.mkv")
+
+	writeTestFile(t, src, "some video")
+	err := syscall.Setxattr(src, "user.mediarename.test", []byte("value"), 0)
+	if errors.Is(err, syscall.ENOTSUP) {
+		t.Skip("extended attributes not supported by filesystem")
+	}
+
+	RequireNoError(t, err)
+	RequireNoError(t, copyFile(src, dst, copyOptions{preserve: &Preserve{Xattrs: true}}))
+
+	val := make([]byte, 16)
+	n, err := syscall.Getxattr(dst, "user.mediarename.test", val)
+	RequireNoError(t, err)
+	RequireEqual(t, "value", string(val[:n]))
+}
+
+func TestTvRenamer_RenameFilesHardlinkOwner(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src", "video.mkv")
+	dst := filepath.Join(dir, "dest", "video.mkv")
+	writeTestFile(t, src, "some video")
+
+	before, err := os.Stat(src)
+	RequireNoError(t, err)
+
+	// Changing the owner would either fail or, as root, change the original
+	owner := &Owner{UID: os.Getuid() + 1, GID: -1}
+	renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true, Mode: ModeHardlink, Owner: owner}, slog.New(slog.DiscardHandler))
+	RequireNoError(t, renamer.RenameFiles([]Rename{{Old: src, New: dst}}))
+
+	after, err := os.Stat(src)
+	RequireNoError(t, err)
+	RequireEqual(t, before.Sys().(*syscall.Stat_t).Uid, after.Sys().(*syscall.Stat_t).Uid)
+}
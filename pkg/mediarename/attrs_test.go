@@ -0,0 +1,77 @@
+package mediarename
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParsePreserve(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		p, err := ParsePreserve([]string{"times", "xattrs"})
+
+		RequireNoError(t, err)
+		RequireEqual(t, Preserve{Times: true, Xattrs: true}, p)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ParsePreserve([]string{"acls"})
+
+		RequireEqual(t, true, err != nil)
+	})
+}
+
+func TestCopyFilePreserve(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.mkv")
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	writeTestFile(t, src, "some video")
+	RequireNoError(t, os.Chmod(src, 0750|os.ModeSetgid))
+	RequireNoError(t, os.Chtimes(src, mtime, mtime))
+
+	t.Run("not preserved", func(t *testing.T) {
+		dst := filepath.Join(dir, "dst.mkv")
+		RequireNoError(t, copyFile(src, dst, copyOptions{preserve: &Preserve{Owner: true}}))
+
+		// Copies get the same permissions as any other new file
+		f, err := os.OpenFile(filepath.Join(dir, "new.mkv"), os.O_CREATE|os.O_WRONLY, 0666)
+		RequireNoError(t, err)
+		RequireNoError(t, f.Close())
+		expected, err := os.Stat(f.Name())
+		RequireNoError(t, err)
+
+		info, err := os.Stat(dst)
+		RequireNoError(t, err)
+		RequireEqual(t, false, mtime.Equal(info.ModTime()))
+		RequireEqual(t, expected.Mode(), info.Mode())
+	})
+
+	t.Run("mode", func(t *testing.T) {
+		dst := filepath.Join(dir, "mode.mkv")
+		RequireNoError(t, copyFile(src, dst, copyOptions{preserve: &Preserve{Mode: true}}))
+
+		info, err := os.Stat(dst)
+		RequireNoError(t, err)
+		RequireEqual(t, os.FileMode(0750)|os.ModeSetgid, info.Mode()&(os.ModePerm|os.ModeSetgid))
+	})
+}
+
+func TestTvRenamer_RenameFilesDirMode(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src", "video.mkv")
+	dst := filepath.Join(dir, "dest", "show", "season_01", "video.mkv")
+	writeTestFile(t, src, "some video")
+
+	owner := &Owner{UID: -1, GID: os.Getgid()}
+	renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true, DirMode: 0750 | os.ModeSetgid, Owner: owner}, slog.New(slog.DiscardHandler))
+	RequireNoError(t, renamer.RenameFiles([]Rename{{Old: src, New: dst}}))
+
+	for _, d := range []string{filepath.Join(dir, "dest"), filepath.Join(dir, "dest", "show"), filepath.Dir(dst)} {
+		info, err := os.Stat(d)
+		RequireNoError(t, err)
+		RequireEqual(t, os.FileMode(0750)|os.ModeSetgid, info.Mode()&(os.ModePerm|os.ModeSetgid))
+	}
+}
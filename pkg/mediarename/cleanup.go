@@ -0,0 +1,165 @@
+package mediarename
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultJunkPatterns returns patterns for files and directories commonly left behind
+// in release directories: release notes, checksums, links, samples, and proof images.
+func DefaultJunkPatterns() []string {
+	return []string{
+		"*.nfo",
+		"*.txt",
+		"*.sfv",
+		"*.md5",
+		"*.url",
+		"*.jpg",
+		"*.png",
+		"sample",
+		"proof",
+		"screens",
+		"thumbs.db",
+		".ds_store",
+	}
+}
+
+// Cleanup removes directories under src that are empty once renamed files have been
+// moved out of them, along with any parents left empty, but never src itself. If junk
+// patterns are given, files and directories with names matching them are deleted too,
+// but only from directories that would otherwise be empty so nothing is removed from
+// directories with files that weren't renamed. Patterns use path.Match syntax and are
+// matched case-insensitively. Everything that would be deleted is only logged, not
+// deleted, unless committing. Should only be called after renaming succeeds.
+func (r *TvRenamer) Cleanup(src string, renames []Rename, junk []string) error {
+	for _, pattern := range junk {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid junk pattern %s: %w", pattern, err)
+		}
+	}
+
+	mode := r.opts.Mode
+	if mode == "" {
+		mode = ModeMove
+	}
+
+	// Without committing nothing has moved yet, so track what would be gone instead
+	gone := make(map[string]struct{})
+	pending := make(map[string]struct{})
+	for _, op := range renames {
+		if op.Skip {
+			continue
+		}
+
+		for _, member := range op.group() {
+			if mode == ModeMove {
+				gone[filepath.Clean(member.Old)] = struct{}{}
+			}
+
+			pending[filepath.Dir(member.Old)] = struct{}{}
+		}
+	}
+
+	src = filepath.Clean(src)
+	for len(pending) > 0 {
+		// Children are handled before their parents so that emptied parents are found
+		dir := deepest(pending)
+		delete(pending, dir)
+
+		if dir == src || !isUnder(src, dir) {
+			continue
+		}
+
+		removable, junkFiles, err := emptyExceptJunk(dir, gone, junk)
+		if err != nil {
+			return err
+		}
+
+		if !removable {
+			continue
+		}
+
+		for _, p := range junkFiles {
+			r.logger.Info("remove junk", "path", p)
+			if r.opts.Commit {
+				if err := os.RemoveAll(p); err != nil {
+					return fmt.Errorf("unable to remove junk %s: %w", p, err)
+				}
+			}
+
+			gone[p] = struct{}{}
+		}
+
+		r.logger.Info("remove empty directory", "dir", dir)
+		if r.opts.Commit {
+			if err := os.Remove(dir); err != nil {
+				return fmt.Errorf("unable to remove empty directory %s: %w", dir, err)
+			}
+		}
+
+		gone[dir] = struct{}{}
+		pending[filepath.Dir(dir)] = struct{}{}
+	}
+
+	return nil
+}
+
+// emptyExceptJunk returns true if dir contains nothing except entries that are gone or
+// match a junk pattern, along with the paths of the junk entries.
+func emptyExceptJunk(dir string, gone map[string]struct{}, junk []string) (bool, []string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, nil, fmt.Errorf("unable to read directory %s: %w", dir, err)
+	}
+
+	var junkFiles []string
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		if _, ok := gone[p]; ok {
+			continue
+		}
+
+		if !isJunk(e.Name(), junk) {
+			return false, nil, nil
+		}
+
+		junkFiles = append(junkFiles, p)
+	}
+
+	return true, junkFiles, nil
+}
+
+// isJunk returns true if name matches any of the junk patterns, ignoring case.
+func isJunk(name string, junk []string) bool {
+	for _, pattern := range junk {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name)); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deepest returns the directory with the most path components.
+func deepest(dirs map[string]struct{}) string {
+	var out string
+	depth := -1
+	for dir := range dirs {
+		d := strings.Count(dir, string(filepath.Separator))
+		if d > depth || (d == depth && dir > out) {
+			out = dir
+			depth = d
+		}
+	}
+
+	return out
+}
+
+// isUnder returns true if p is base or a path below it.
+func isUnder(base string, p string) bool {
+	rel, err := filepath.Rel(base, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,86 @@
+package mediarename
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTvRenamer_Cleanup(t *testing.T) {
+	// setup creates a source tree with a release directory that only has junk left once
+	// the video is moved and a directory with a file that isn't renamed.
+	setup := func(t *testing.T) (string, []Rename) {
+		src := filepath.Join(t.TempDir(), "src")
+		writeTestFile(t, filepath.Join(src, "release", "show.s01e01.mkv"), "video")
+		writeTestFile(t, filepath.Join(src, "release", "release.nfo"), "nfo")
+		writeTestFile(t, filepath.Join(src, "release", "Sample", "sample.mkv"), "sample")
+		writeTestFile(t, filepath.Join(src, "nested", "release", "show.s01e02.mkv"), "video")
+		writeTestFile(t, filepath.Join(src, "other", "show.s01e03.mkv"), "video")
+		writeTestFile(t, filepath.Join(src, "other", "show.s01e03.nfo"), "nfo")
+
+		dest := filepath.Join(filepath.Dir(src), "dest")
+		return src, []Rename{
+			{Old: filepath.Join(src, "release", "show.s01e01.mkv"), New: filepath.Join(dest, "s01e01.mkv")},
+			{Old: filepath.Join(src, "nested", "release", "show.s01e02.mkv"), New: filepath.Join(dest, "s01e02.mkv")},
+		}
+	}
+
+	exists := func(t *testing.T, p string) bool {
+		_, err := os.Stat(p)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			t.Fatal(err)
+		}
+
+		return err == nil
+	}
+
+	t.Run("empty directories", func(t *testing.T) {
+		src, renames := setup(t)
+		renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.RenameFiles(renames))
+		RequireNoError(t, renamer.Cleanup(src, renames, nil))
+
+		RequireEqual(t, false, exists(t, filepath.Join(src, "nested")))
+		RequireEqual(t, true, exists(t, filepath.Join(src, "release", "release.nfo")))
+		RequireEqual(t, true, exists(t, src))
+	})
+
+	t.Run("junk", func(t *testing.T) {
+		src, renames := setup(t)
+		renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.RenameFiles(renames))
+		RequireNoError(t, renamer.Cleanup(src, renames, DefaultJunkPatterns()))
+
+		RequireEqual(t, false, exists(t, filepath.Join(src, "release")))
+		RequireEqual(t, false, exists(t, filepath.Join(src, "nested")))
+		RequireEqual(t, true, exists(t, filepath.Join(src, "other", "show.s01e03.nfo")))
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		src, renames := setup(t)
+		var logs bytes.Buffer
+		renamer := NewTvRenamer(&fakeClient{}, TvOptions{}, slog.New(slog.NewTextHandler(&logs, nil)))
+		RequireNoError(t, renamer.Cleanup(src, renames, DefaultJunkPatterns()))
+
+		out := logs.String()
+		RequireEqual(t, true, strings.Contains(out, `msg="remove junk" path=`+filepath.Join(src, "release", "Sample")))
+		RequireEqual(t, true, strings.Contains(out, `msg="remove empty directory" dir=`+filepath.Join(src, "nested")+"\n"))
+
+		RequireEqual(t, true, exists(t, filepath.Join(src, "release", "release.nfo")))
+		RequireEqual(t, true, exists(t, filepath.Join(src, "nested", "release")))
+	})
+
+	t.Run("copy leaves sources", func(t *testing.T) {
+		src, renames := setup(t)
+		renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true, Mode: ModeCopy}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.RenameFiles(renames))
+		RequireNoError(t, renamer.Cleanup(src, renames, DefaultJunkPatterns()))
+
+		RequireEqual(t, true, exists(t, filepath.Join(src, "release", "show.s01e01.mkv")))
+		RequireEqual(t, true, exists(t, filepath.Join(src, "release", "release.nfo")))
+	})
+}
@@ -7,9 +7,17 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
+	"sync"
+	"time"
 )
 
-const userAgent = "mediarename/0.1.0 (https://github.com/56quarters/mediarename)"
+const (
+	userAgent = "mediarename/0.1.0 (https://github.com/56quarters/mediarename)"
+
+	// rateLimit and ratePeriod are the documented TVmaze API rate limit.
+	rateLimit  = 20
+	ratePeriod = 10 * time.Second
+)
 
 type ErrorResponse struct {
 	Name    string `json:"name"`
@@ -25,9 +33,14 @@ type ErrorResponse struct {
 }
 
 type Show struct {
-	ID        int    `json:"id"`
-	URL       string `json:"url"`
-	Name      string `json:"name"`
+	ID        int      `json:"id"`
+	URL       string   `json:"url"`
+	Name      string   `json:"name"`
+	Premiered string   `json:"premiered"`
+	Runtime   int      `json:"runtime"`
+	Genres    []string `json:"genres"`
+	Summary   string   `json:"summary"`
+	Image     *Image   `json:"image"`
 	Externals struct {
 		TvRage  int    `json:"tvrage"`
 		TheTvDb int    `json:"thetvdb"`
@@ -38,12 +51,61 @@ type Show struct {
 type Episodes []Episode
 
 type Episode struct {
+	ID      int    `json:"id"`
+	URL     string `json:"url"`
+	Name    string `json:"name"`
+	Season  int    `json:"season"`
+	Number  int    `json:"number"`
+	Type    string `json:"type"`
+	Airdate string `json:"airdate"`
+	Runtime int    `json:"runtime"`
+	Summary string `json:"summary"`
+	Image   *Image `json:"image"`
+}
+
+type Season struct {
 	ID     int    `json:"id"`
-	URL    string `json:"url"`
-	Name   string `json:"name"`
-	Season int    `json:"season"`
 	Number int    `json:"number"`
-	Type   string `json:"type"`
+	Image  *Image `json:"image"`
+}
+
+// Image is artwork in different sizes. Either URL may be empty.
+type Image struct {
+	Medium   string `json:"medium"`
+	Original string `json:"original"`
+}
+
+// URL returns the URL of the largest available size of the image.
+func (i *Image) URL() string {
+	if i == nil {
+		return ""
+	}
+
+	if i.Original != "" {
+		return i.Original
+	}
+
+	return i.Medium
+}
+
+type Aka struct {
+	Name    string `json:"name"`
+	Country *struct {
+		Name     string `json:"name"`
+		Code     string `json:"code"`
+		Timezone string `json:"timezone"`
+	} `json:"country"`
+}
+
+type CastMember struct {
+	Person struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"person"`
+	Character struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"character"`
 }
 
 type ImdbID string
@@ -53,10 +115,55 @@ type MediaClient interface {
 	Episodes(show *Show) (Episodes, error)
 }
 
+// ShowTranslator is implemented by a MediaClient that can provide the name of a show
+// in a language other than the primary language of the provider. Implementations return
+// an empty string and no error when there is no translation available.
+type ShowTranslator interface {
+	ShowName(show *Show, lang Language) (string, error)
+}
+
+// EpisodeTranslator is implemented by a MediaClient that can provide the names of episodes
+// in a language other than the primary language of the provider. Implementations return
+// an empty string and no error when there is no translation available.
+type EpisodeTranslator interface {
+	EpisodeName(show *Show, episode Episode, lang Language) (string, error)
+}
+
+// EpisodeIDs are identifiers of a single episode from metadata providers other than the
+// one it was found with. Unknown identifiers are zero or empty.
+type EpisodeIDs struct {
+	Imdb    string
+	TheTvDb int
+	Tmdb    int
+}
+
+// EpisodeIDFinder is implemented by a MediaClient that can provide identifiers of an
+// episode from other metadata providers. Implementations return empty EpisodeIDs and no
+// error when the episode can't be found.
+type EpisodeIDFinder interface {
+	EpisodeIDs(show *Show, episode Episode) (EpisodeIDs, error)
+}
+
+// CastLister is implemented by a MediaClient that can provide the main cast of a show.
+type CastLister interface {
+	Cast(show *Show) ([]CastMember, error)
+}
+
+// ArtworkFetcher is implemented by a MediaClient that can provide artwork for each
+// season of a show and download images.
+type ArtworkFetcher interface {
+	Seasons(show *Show) ([]Season, error)
+	Download(imageURL string) ([]byte, error)
+}
+
 type TvMazeClient struct {
 	client  *http.Client
 	baseURL *url.URL
+	limiter *RateLimiter
 	logger  *slog.Logger
+
+	mu     sync.Mutex
+	images map[string][]byte
 }
 
 func NewTvMazeClient(base string, client *http.Client, logger *slog.Logger) (*TvMazeClient, error) {
@@ -68,7 +175,9 @@ func NewTvMazeClient(base string, client *http.Client, logger *slog.Logger) (*Tv
 	return &TvMazeClient{
 		client:  client,
 		baseURL: u,
+		limiter: NewRateLimiter(rateLimit, ratePeriod),
 		logger:  logger,
+		images:  make(map[string][]byte),
 	}, nil
 }
 
@@ -81,7 +190,7 @@ func (c *TvMazeClient) ShowByImdb(imdb ImdbID) (*Show, error) {
 	}
 
 	c.logger.Debug("looking up show by imdb ID", "id", imdb, "url", r.URL)
-	res, err := c.client.Do(r)
+	res, err := c.do(r)
 	if err != nil {
 		return nil, fmt.Errorf("unable to lookup show by ID: %w", err)
 	}
@@ -112,7 +221,7 @@ func (c *TvMazeClient) Episodes(show *Show) (Episodes, error) {
 	}
 
 	c.logger.Debug("looking up episodes by native ID", "id", show.ID, "url", r.URL)
-	res, err := c.client.Do(r)
+	res, err := c.do(r)
 	if err != nil {
 		return nil, fmt.Errorf("unable to lookup episodes by show ID %d: %w", show.ID, err)
 	}
@@ -134,6 +243,126 @@ func (c *TvMazeClient) Episodes(show *Show) (Episodes, error) {
 	return episodes, nil
 }
 
+// ShowName implements the ShowTranslator interface using alternate names for the
+// show, which TVmaze associates with a country rather than a language.
+func (c *TvMazeClient) ShowName(show *Show, lang Language) (string, error) {
+	akas, err := c.Akas(show)
+	if err != nil {
+		return "", err
+	}
+
+	for _, country := range lang.Countries() {
+		for _, aka := range akas {
+			if aka.Country != nil && aka.Country.Code == country {
+				return aka.Name, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// Akas returns alternate names for a show.
+func (c *TvMazeClient) Akas(show *Show) ([]Aka, error) {
+	p := fmt.Sprintf("shows/%d/akas", show.ID)
+	var akas []Aka
+	if err := c.getJSON(p, "", &akas); err != nil {
+		return nil, fmt.Errorf("unable to lookup akas by show ID %d: %w", show.ID, err)
+	}
+
+	return akas, nil
+}
+
+// Cast implements the CastLister interface
+func (c *TvMazeClient) Cast(show *Show) ([]CastMember, error) {
+	p := fmt.Sprintf("shows/%d/cast", show.ID)
+	var cast []CastMember
+	if err := c.getJSON(p, "", &cast); err != nil {
+		return nil, fmt.Errorf("unable to lookup cast by show ID %d: %w", show.ID, err)
+	}
+
+	return cast, nil
+}
+
+// Seasons implements the ArtworkFetcher interface
+func (c *TvMazeClient) Seasons(show *Show) ([]Season, error) {
+	p := fmt.Sprintf("shows/%d/seasons", show.ID)
+	var seasons []Season
+	if err := c.getJSON(p, "", &seasons); err != nil {
+		return nil, fmt.Errorf("unable to lookup seasons by show ID %d: %w", show.ID, err)
+	}
+
+	return seasons, nil
+}
+
+// Download implements the ArtworkFetcher interface. Images are cached so that each is
+// only downloaded once.
+func (c *TvMazeClient) Download(imageURL string) ([]byte, error) {
+	c.mu.Lock()
+	cached, ok := c.images[imageURL]
+	c.mu.Unlock()
+
+	if ok {
+		c.logger.Debug("using cached image", "url", imageURL)
+		return cached, nil
+	}
+
+	r, err := http.NewRequest("GET", imageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build request for image %s: %w", imageURL, err)
+	}
+
+	r.Header.Set("user-agent", userAgent)
+	c.logger.Debug("downloading image", "url", imageURL)
+	res, err := c.do(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download image %s: %w", imageURL, err)
+	}
+
+	defer c.drainAndClose(res.Body)
+
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("unable to download image %s: non-success status code %d", imageURL, res.StatusCode)
+	}
+
+	image, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download image %s: %w", imageURL, err)
+	}
+
+	c.mu.Lock()
+	c.images[imageURL] = image
+	c.mu.Unlock()
+
+	return image, nil
+}
+
+func (c *TvMazeClient) getJSON(path string, params string, out any) error {
+	r, err := c.request(path, params)
+	if err != nil {
+		return fmt.Errorf("unable to build request: %w", err)
+	}
+
+	c.logger.Debug("making API request", "url", r.URL)
+	res, err := c.do(r)
+	if err != nil {
+		return err
+	}
+
+	defer c.drainAndClose(res.Body)
+
+	c.logger.Debug("API response", "status", res.Status)
+	if res.StatusCode != 200 {
+		return fmt.Errorf("non-success status code %d", res.StatusCode)
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("unable to deserialize JSON: %w", err)
+	}
+
+	return nil
+}
+
 func (c *TvMazeClient) request(path string, params string) (*http.Request, error) {
 	requestURL := url.URL{
 		Scheme:   c.baseURL.Scheme,
@@ -153,6 +382,12 @@ func (c *TvMazeClient) request(path string, params string) (*http.Request, error
 	return req, nil
 }
 
+// do makes a request once the rate limiter allows it.
+func (c *TvMazeClient) do(r *http.Request) (*http.Response, error) {
+	c.limiter.Wait()
+	return c.client.Do(r)
+}
+
 func (c *TvMazeClient) drainAndClose(body io.ReadCloser) {
 	_, _ = io.Copy(io.Discard, body)
 	_ = body.Close()
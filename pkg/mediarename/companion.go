@@ -0,0 +1,157 @@
+package mediarename
+
+import (
+	"path"
+	"strings"
+)
+
+var (
+	// companionSuffixes are the endings of files, other than subtitles, that belong to
+	// the video with the same name in the same directory.
+	companionSuffixes = []string{
+		"-thumb.jpg",
+		".chapters.xml",
+		".nfo",
+	}
+)
+
+// MediaFile is a file to rename along with companion files that belong to it, such as
+// subtitles, metadata, or thumbnails. Companions are renamed with the file as a unit.
+type MediaFile struct {
+	Path       string
+	Companions []string
+}
+
+// GroupFiles groups companion files with the video in the same directory that shares
+// their name, e.g. "show.s01e01.nfo" and "show.s01e01.en.srt" with "show.s01e01.mkv".
+// Subtitles without a video are renamed on their own, keeping VobSub ".idx" and ".sub"
+// pairs together. Any other companions without a video are returned as orphans.
+func GroupFiles(files []string) ([]MediaFile, []string) {
+	var out []MediaFile
+	var companions []string
+	primaries := make(map[string]int)
+
+	for _, file := range files {
+		if isCompanion(file) {
+			companions = append(companions, file)
+			continue
+		}
+
+		primaries[trimExt(file)] = len(out)
+		out = append(out, MediaFile{Path: file})
+	}
+
+	var orphans []string
+	var subtitles []string
+	for _, file := range companions {
+		if i, ok := primaryIndex(file, primaries); ok {
+			out[i].Companions = append(out[i].Companions, file)
+		} else if IsSubtitle(file) {
+			subtitles = append(subtitles, file)
+		} else {
+			orphans = append(orphans, file)
+		}
+	}
+
+	loose := make(map[string]struct{}, len(subtitles))
+	for _, file := range subtitles {
+		loose[file] = struct{}{}
+	}
+
+	for _, file := range subtitles {
+		if strings.EqualFold(path.Ext(file), ".sub") {
+			if _, ok := loose[trimExt(file)+".idx"]; ok {
+				continue
+			}
+		}
+
+		sub := MediaFile{Path: file}
+		if strings.EqualFold(path.Ext(file), ".idx") {
+			if _, ok := loose[trimExt(file)+".sub"]; ok {
+				sub.Companions = append(sub.Companions, trimExt(file)+".sub")
+			}
+		}
+
+		out = append(out, sub)
+	}
+
+	return out, orphans
+}
+
+// isCompanion returns true if file belongs to a video rather than being a video itself.
+func isCompanion(file string) bool {
+	if IsSubtitle(file) {
+		return true
+	}
+
+	_, ok := companionSuffix(file)
+	return ok
+}
+
+// companionSuffix returns the ending of file that follows the name of its video, if it
+// is a companion other than a subtitle.
+func companionSuffix(file string) (string, bool) {
+	base := path.Base(file)
+	for _, suffix := range companionSuffixes {
+		if len(base) > len(suffix) && strings.EqualFold(base[len(base)-len(suffix):], suffix) {
+			return base[len(base)-len(suffix):], true
+		}
+	}
+
+	return "", false
+}
+
+// primaryIndex returns the index of the video that a companion file belongs to. primaries
+// are indexes of videos keyed by their path without an extension.
+func primaryIndex(file string, primaries map[string]int) (int, bool) {
+	dir := path.Dir(file)
+	if suffix, ok := companionSuffix(file); ok {
+		i, ok := primaries[path.Join(dir, strings.TrimSuffix(path.Base(file), suffix))]
+		return i, ok
+	}
+
+	// Prefer the longest stem in case a video name ends with something that looks like
+	// a subtitle language code.
+	parts := subtitleParts(file)
+	for i := len(parts) - 1; i >= 0; i-- {
+		index, ok := primaries[path.Join(dir, strings.Join(parts[:i+1], "."))]
+		if !ok {
+			continue
+		}
+
+		if _, ok := parseSubtitleQualifiers(parts[i+1:]); ok {
+			return index, true
+		}
+	}
+
+	return 0, false
+}
+
+// companionName returns the new name of a companion file of the file renamed by op: the
+// new name of the file without its extension followed by the part of the companion name
+// after the original name of the file. Subtitle qualifiers are normalized.
+func companionName(op Rename, companion string) string {
+	return trimExt(op.New) + companionRest(op.Old, companion)
+}
+
+// companionRest returns the part of the name of a companion file after the name of the
+// file it belongs to, without its extension, e.g. ".en.sdh.srt" or ".nfo".
+func companionRest(file string, companion string) string {
+	rest := strings.TrimPrefix(path.Base(companion), path.Base(trimExt(file)))
+	if IsSubtitle(companion) {
+		ext := path.Ext(companion)
+		var sub Subtitle
+		if qualifiers := strings.Trim(strings.TrimSuffix(rest, ext), "."); qualifiers != "" {
+			sub, _ = parseSubtitleQualifiers(strings.Split(qualifiers, "."))
+		}
+
+		rest = sub.Suffix() + ext
+	}
+
+	return rest
+}
+
+// trimExt returns p without its extension.
+func trimExt(p string) string {
+	return strings.TrimSuffix(p, path.Ext(p))
+}
@@ -0,0 +1,151 @@
+package mediarename
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGroupFiles(t *testing.T) {
+	t.Run("companions", func(t *testing.T) {
+		files, orphans := GroupFiles([]string{
+			"src/Show.S01E01.en.srt",
+			"src/Show.S01E01.mkv",
+			"src/Show.S01E01.nfo",
+			"src/Show.S01E01-thumb.jpg",
+			"src/Show.S01E01.chapters.xml",
+			"src/Show.S01E01.idx",
+			"src/Show.S01E01.sub",
+			"other/Show.S01E01.nfo",
+		})
+
+		RequireEqual(t, 1, len(files))
+		RequireEqual(t, "src/Show.S01E01.mkv", files[0].Path)
+		RequireEqual(t, 6, len(files[0].Companions))
+		RequireEqual(t, "src/Show.S01E01.en.srt", files[0].Companions[0])
+		RequireEqual(t, 1, len(orphans))
+		RequireEqual(t, "other/Show.S01E01.nfo", orphans[0])
+	})
+
+	t.Run("subtitles without video", func(t *testing.T) {
+		files, orphans := GroupFiles([]string{
+			"src/Show.S01E01.sub",
+			"src/Show.S01E01.idx",
+			"src/Show.S01E02.en.srt",
+		})
+
+		RequireEqual(t, 2, len(files))
+		RequireEqual(t, "src/Show.S01E01.idx", files[0].Path)
+		RequireEqual(t, 1, len(files[0].Companions))
+		RequireEqual(t, "src/Show.S01E01.sub", files[0].Companions[0])
+		RequireEqual(t, "src/Show.S01E02.en.srt", files[1].Path)
+		RequireEqual(t, 0, len(orphans))
+	})
+}
+
+func TestTvRenamer_GenerateNamesCompanions(t *testing.T) {
+	client := &fakeClient{show: testShow, episodes: testEpisodes}
+	renamer := NewTvRenamer(client, TvOptions{}, slog.New(slog.DiscardHandler))
+
+	t.Run("matched to video", func(t *testing.T) {
+		renames, err := renamer.GenerateNames(mediaFiles(
+			"src/Show.S01E01.en.srt",
+			"src/Show.S01E01.mkv",
+			"src/Show.S01E01.eng.forced.srt",
+			"src/Show.S01E01.pt_br.cc.vtt",
+			"src/Show.S01E01-thumb.jpg",
+		), "dest", "tt1234")
+
+		RequireNoError(t, err)
+		RequireEqual(t, 1, len(renames))
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e01-pilot.mkv", renames[0].New)
+		RequireEqual(t, 4, len(renames[0].Companions))
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e01-pilot.en.srt", renames[0].Companions[0].New)
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e01-pilot.en.forced.srt", renames[0].Companions[1].New)
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e01-pilot.pt-BR.sdh.vtt", renames[0].Companions[2].New)
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e01-pilot-thumb.jpg", renames[0].Companions[3].New)
+	})
+
+	t.Run("languages without a known country", func(t *testing.T) {
+		renames, err := renamer.GenerateNames(mediaFiles(
+			"src/Show.S01E01.mkv",
+			"src/Show.S01E01.hr.srt",
+			"src/Show.S01E01.bg.srt",
+		), "dest", "tt1234")
+
+		RequireNoError(t, err)
+		RequireEqual(t, 1, len(renames))
+		RequireEqual(t, 2, len(renames[0].Companions))
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e01-pilot.hr.srt", renames[0].Companions[0].New)
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e01-pilot.bg.srt", renames[0].Companions[1].New)
+		RequireEqual(t, false, renames[0].Companions[0].Skip)
+		RequireEqual(t, false, renames[0].Companions[1].Skip)
+	})
+
+	t.Run("without video", func(t *testing.T) {
+		renames, err := renamer.GenerateNames(mediaFiles(
+			"src/Show.S01E02.de.srt",
+			"src/Show.S01E02.idx",
+			"src/Show.S01E02.sub",
+		), "dest", "tt1234")
+
+		RequireNoError(t, err)
+		RequireEqual(t, 2, len(renames))
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e02-events.de.srt", renames[0].New)
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e02-events.idx", renames[1].New)
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e02-events.sub", renames[1].Companions[0].New)
+	})
+
+	t.Run("follows suffixed name", func(t *testing.T) {
+		dir := t.TempDir()
+		dest := filepath.Join(dir, "dest")
+		writeTestFile(t, filepath.Join(dest, "the_show", "season_01", "the_show-s01e01-pilot.mkv"), "existing")
+
+		renamer := NewTvRenamer(client, TvOptions{OnConflict: ConflictSuffix}, slog.New(slog.DiscardHandler))
+		renames, err := renamer.GenerateNames(mediaFiles("src/Show.S01E01.mkv", "src/Show.S01E01.nfo"), dest, "tt1234")
+
+		RequireNoError(t, err)
+		RequireEqual(t, filepath.Join(dest, "the_show", "season_01", "the_show-s01e01-pilot-2.nfo"), renames[0].Companions[0].New)
+	})
+}
+
+func TestTvRenamer_RenameFilesCompanions(t *testing.T) {
+	setup := func(t *testing.T) (string, Rename) {
+		dir := t.TempDir()
+		writeTestFile(t, filepath.Join(dir, "src", "video.mkv"), "video")
+		writeTestFile(t, filepath.Join(dir, "src", "video.en.srt"), "subtitle")
+
+		return dir, Rename{
+			Old: filepath.Join(dir, "src", "video.mkv"),
+			New: filepath.Join(dir, "dest", "new.mkv"),
+			Companions: []Rename{{
+				Old: filepath.Join(dir, "src", "video.en.srt"),
+				New: filepath.Join(dir, "dest", "new.en.srt"),
+			}},
+		}
+	}
+
+	t.Run("renamed together", func(t *testing.T) {
+		dir, op := setup(t)
+		renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true}, slog.New(slog.DiscardHandler))
+
+		RequireNoError(t, renamer.RenameFiles([]Rename{op}))
+		RequireEqual(t, "video", readTestFile(t, filepath.Join(dir, "dest", "new.mkv")))
+		RequireEqual(t, "subtitle", readTestFile(t, filepath.Join(dir, "dest", "new.en.srt")))
+	})
+
+	t.Run("companion failure rolls back group", func(t *testing.T) {
+		dir, op := setup(t)
+		writeTestFile(t, op.Companions[0].New, "existing")
+		renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true}, slog.New(slog.DiscardHandler))
+
+		err := renamer.RenameFiles([]Rename{op})
+		RequireErrorIs(t, err, ErrConflict)
+		RequireEqual(t, "video", readTestFile(t, filepath.Join(dir, "src", "video.mkv")))
+
+		_, err = os.Stat(op.New)
+		RequireEqual(t, true, errors.Is(err, os.ErrNotExist))
+	})
+}
@@ -0,0 +1,54 @@
+package mediarename
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Config is settings read from a JSON config file. Settings given as flags take
+// precedence over the config file.
+type Config struct {
+	// Template is the template for new file names, used instead of DefaultTemplate
+	// when no template is given as a flag.
+	Template string `json:"template,omitempty"`
+}
+
+// DefaultConfigPath returns the config file used when one isn't specified:
+// $XDG_CONFIG_HOME/mediarename/config.json or ~/.config/mediarename/config.json.
+func DefaultConfigPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "mediarename", "config.json")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".config", "mediarename", "config.json")
+}
+
+// ReadConfig reads the config file at p. If optional is true, a missing file is
+// treated as an empty config.
+func ReadConfig(p string, optional bool) (Config, error) {
+	var cfg Config
+	if p == "" {
+		return cfg, nil
+	}
+
+	b, err := os.ReadFile(p)
+	if optional && errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	} else if err != nil {
+		return cfg, fmt.Errorf("unable to read config: %w", err)
+	}
+
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("unable to parse config %s: %w", p, err)
+	}
+
+	return cfg, nil
+}
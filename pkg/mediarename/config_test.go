@@ -0,0 +1,30 @@
+package mediarename
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadConfig(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "config.json")
+	writeTestFile(t, p, `{"template": "{{.Show}}/{{.Show}} - {{.Tag}}"}`)
+
+	t.Run("template", func(t *testing.T) {
+		cfg, err := ReadConfig(p, false)
+		RequireNoError(t, err)
+		RequireEqual(t, "{{.Show}}/{{.Show}} - {{.Tag}}", cfg.Template)
+	})
+
+	t.Run("missing optional", func(t *testing.T) {
+		cfg, err := ReadConfig(filepath.Join(dir, "missing.json"), true)
+		RequireNoError(t, err)
+		RequireEqual(t, Config{}, cfg)
+	})
+
+	t.Run("missing required", func(t *testing.T) {
+		_, err := ReadConfig(filepath.Join(dir, "missing.json"), false)
+		RequireErrorIs(t, err, os.ErrNotExist)
+	})
+}
@@ -0,0 +1,433 @@
+package mediarename
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrConflict = errors.New("conflicting file names")
+)
+
+// ConflictPolicy decides what happens when a new name is already in use.
+type ConflictPolicy string
+
+const (
+	// ConflictSkip leaves files with conflicting names where they are.
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictFail refuses to rename any files if there are conflicts.
+	ConflictFail ConflictPolicy = "fail"
+	// ConflictOverwrite replaces existing files with the renamed file.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictSuffix adds a numeric suffix to names to make them unique.
+	ConflictSuffix ConflictPolicy = "suffix"
+	// ConflictKeepLarger keeps whichever of the conflicting files is largest.
+	ConflictKeepLarger ConflictPolicy = "keep-larger"
+)
+
+// ConflictPolicies returns the names of all supported conflict policies.
+func ConflictPolicies() []string {
+	return []string{
+		string(ConflictSkip),
+		string(ConflictFail),
+		string(ConflictOverwrite),
+		string(ConflictSuffix),
+		string(ConflictKeepLarger),
+	}
+}
+
+// ConflictKind is the reason a new name conflicts with another file.
+type ConflictKind string
+
+const (
+	// ConflictDuplicate means multiple files would be renamed to the same name.
+	ConflictDuplicate ConflictKind = "duplicate"
+	// ConflictExists means a file already exists with the new name.
+	ConflictExists ConflictKind = "exists"
+	// ConflictCase means a file name differs from another only by case and the
+	// destination filesystem is case-insensitive.
+	ConflictCase ConflictKind = "case"
+)
+
+// Conflict describes why a new name conflicts with another file.
+type Conflict struct {
+	Kind ConflictKind `json:"kind"`
+	// With is the path of the file that the new name conflicts with.
+	With string `json:"with"`
+}
+
+// conflictGroup is every rename that would end up with the same name, along with
+// any existing file at that name.
+type conflictGroup struct {
+	indexes  []int
+	existing string
+	kind     ConflictKind
+}
+
+// resolveConflicts finds renames whose new names conflict with each other or with existing
+// files and applies the configured conflict policy to them. Every conflict is logged. An error
+// wrapping ErrConflict is returned if there are conflicts and the policy is ConflictFail.
+func (r *TvRenamer) resolveConflicts(renames []Rename, dest string) ([]Rename, error) {
+	policy := r.opts.OnConflict
+	if policy == "" {
+		policy = ConflictSkip
+	}
+
+	// Names that differ only by case also clash for Windows clients, even if the
+	// filesystem they are served from is case-sensitive.
+	fold := caseInsensitive(dest)
+	if san := r.opts.Sanitizer; san != nil && (san.Profile == ProfileWindows || san.Profile == ProfileFat32) {
+		fold = true
+	}
+	key := func(p string) string {
+		if fold {
+			return strings.ToLower(p)
+		}
+
+		return p
+	}
+
+	groups := make(map[string]*conflictGroup)
+	var order []string
+	for i, op := range renames {
+		// Links from a previous run or files that are already named correctly
+		if inPlace(op.Old, op.New, fold) {
+			r.logger.Info("already in place", "old", op.Old, "new", op.New)
+			renames[i].Skip = true
+			continue
+		}
+
+		k := key(op.New)
+		g, ok := groups[k]
+		if !ok {
+			g = &conflictGroup{kind: ConflictDuplicate}
+			groups[k] = g
+			order = append(order, k)
+		}
+
+		if len(g.indexes) > 0 && renames[g.indexes[0]].New != op.New {
+			g.kind = ConflictCase
+		}
+
+		g.indexes = append(g.indexes, i)
+	}
+
+	var conflicts int
+	used := make(map[string]struct{}, len(groups))
+	for _, k := range order {
+		used[k] = struct{}{}
+	}
+
+	for _, k := range order {
+		g := groups[k]
+		first := renames[g.indexes[0]]
+		// A case-only rename finds the file being renamed, which isn't a conflict
+		if existing, ok := existingName(first.New, fold); ok && !(len(g.indexes) == 1 && sameFile(existing, first.Old)) {
+			g.existing = existing
+			if len(g.indexes) == 1 {
+				g.kind = ConflictExists
+				if existing != first.New {
+					g.kind = ConflictCase
+				}
+			}
+		}
+
+		if len(g.indexes) == 1 && g.existing == "" {
+			continue
+		}
+
+		conflicts++
+		r.resolveGroup(policy, renames, g, used, key)
+	}
+
+	// Companions follow wherever their file ends up after resolving conflicts
+	conflicts += r.resolveCompanions(policy, renames, fold, key)
+
+	if conflicts > 0 && policy == ConflictFail {
+		return nil, fmt.Errorf("%w: %d files would be overwritten or clobbered", ErrConflict, conflicts)
+	}
+
+	return renames, nil
+}
+
+// resolveGroup applies policy to a set of renames with the same new name, marking them
+// to be skipped, overwrite an existing file, or renamed with a unique suffix.
+func (r *TvRenamer) resolveGroup(policy ConflictPolicy, renames []Rename, g *conflictGroup, used map[string]struct{}, key func(string) string) {
+	// The file that keeps the new name, if any. Every other file is skipped or suffixed.
+	winner := -1
+	switch policy {
+	case ConflictOverwrite:
+		winner = g.indexes[0]
+	case ConflictKeepLarger:
+		largest := fileSize(g.existing)
+		for _, i := range g.indexes {
+			if size := fileSize(renames[i].Old); size > largest {
+				winner, largest = i, size
+			}
+		}
+	case ConflictSuffix:
+		if g.existing == "" {
+			winner = g.indexes[0]
+		}
+	}
+
+	for _, i := range g.indexes {
+		op := &renames[i]
+		with := g.existing
+		if with == "" {
+			with = renames[g.indexes[0]].Old
+			if i == g.indexes[0] {
+				with = renames[g.indexes[1]].Old
+			}
+		}
+
+		if i != winner || g.existing != "" {
+			op.Conflict = &Conflict{Kind: g.kind, With: with}
+		}
+
+		switch {
+		case i == winner:
+			op.Overwrite = g.existing != ""
+		case policy == ConflictSuffix:
+			op.New = uniqueName(op.New, used, key)
+		default:
+			op.Skip = true
+		}
+
+		if op.Conflict != nil {
+			r.logger.Warn("conflict", "kind", op.Conflict.Kind, "old", op.Old, "new", op.New, "with", with, "policy", policy, "skip", op.Skip, "overwrite", op.Overwrite)
+		}
+	}
+}
+
+// resolveCompanions names the companions of each rename after its new name and applies
+// policy to companions whose new names are used by an existing file or another rename.
+// Companions can't be given a unique suffix without being separated from their file, so
+// they are skipped unless the policy is to overwrite. Returns the number of conflicts.
+func (r *TvRenamer) resolveCompanions(policy ConflictPolicy, renames []Rename, fold bool, key func(string) string) int {
+	targets := make(map[string]string, len(renames))
+	for _, op := range renames {
+		if !op.Skip {
+			targets[key(op.New)] = op.Old
+		}
+	}
+
+	var conflicts int
+	for i := range renames {
+		op := &renames[i]
+		op.Companions = slices.Clone(op.Companions)
+		for j := range op.Companions {
+			c := &op.Companions[j]
+			c.New = companionName(*op, c.Old)
+			c.Conflict = nil
+			c.Overwrite = false
+			if op.Skip {
+				continue
+			}
+
+			if inPlace(c.Old, c.New, fold) {
+				c.Skip = true
+				continue
+			}
+
+			if with, ok := targets[key(c.New)]; ok {
+				c.Conflict = &Conflict{Kind: ConflictDuplicate, With: with}
+			} else if existing, ok := existingName(c.New, fold); ok && !sameFile(existing, c.Old) {
+				c.Conflict = &Conflict{Kind: ConflictExists, With: existing}
+				if existing != c.New {
+					c.Conflict.Kind = ConflictCase
+				}
+			}
+
+			if c.Conflict == nil {
+				targets[key(c.New)] = c.Old
+				continue
+			}
+
+			conflicts++
+			larger := policy == ConflictKeepLarger && fileSize(c.Old) > fileSize(c.Conflict.With)
+			if c.Conflict.Kind != ConflictDuplicate && (policy == ConflictOverwrite || larger) {
+				c.Overwrite = true
+				targets[key(c.New)] = c.Old
+			} else {
+				c.Skip = true
+			}
+
+			r.logger.Warn("conflict", "kind", c.Conflict.Kind, "old", c.Old, "new", c.New, "with", c.Conflict.With, "policy", policy, "skip", c.Skip, "overwrite", c.Overwrite)
+		}
+	}
+
+	return conflicts
+}
+
+// uniqueName returns p with the first numeric suffix that makes it unique among the used
+// names and existing files, and records it as used.
+func uniqueName(p string, used map[string]struct{}, key func(string) string) string {
+	ext := filepath.Ext(p)
+	base := strings.TrimSuffix(p, ext)
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s-%d%s", base, n, ext)
+		if _, ok := used[key(candidate)]; ok {
+			continue
+		}
+
+		if _, err := os.Lstat(candidate); err == nil {
+			continue
+		}
+
+		used[key(candidate)] = struct{}{}
+		return candidate
+	}
+}
+
+// existingName returns the name of an existing file matching p. If fold is true the
+// match is case-insensitive and the returned name may differ from p in case.
+func existingName(p string, fold bool) (string, bool) {
+	if _, err := os.Lstat(p); err != nil {
+		return "", false
+	}
+
+	if !fold {
+		return p, true
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(p))
+	if err != nil {
+		return p, true
+	}
+
+	base := filepath.Base(p)
+	for _, e := range entries {
+		if e.Name() == base {
+			return p, true
+		}
+	}
+
+	for _, e := range entries {
+		if strings.EqualFold(e.Name(), base) {
+			return filepath.Join(filepath.Dir(p), e.Name()), true
+		}
+	}
+
+	return p, true
+}
+
+// inPlace returns true if the file at old already has the name new. On case-insensitive
+// filesystems a file being renamed to change only the case of its name is not in place
+// until the name stored in its directory matches.
+func inPlace(old string, new string, fold bool) bool {
+	if !sameFile(old, new) {
+		return false
+	}
+
+	if old == new || !strings.EqualFold(old, new) {
+		return true
+	}
+
+	existing, _ := existingName(new, fold)
+	return existing == new
+}
+
+// caseRename returns true if op only changes the case of a name on a filesystem where
+// both names already refer to the same file.
+func caseRename(op Rename) bool {
+	return op.Old != op.New && strings.EqualFold(op.Old, op.New) && sameFile(op.Old, op.New)
+}
+
+// sameFile returns true if a and b are the same existing file.
+func sameFile(a string, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(aInfo, bInfo)
+}
+
+// fileSize returns the size of the file at p or -1 if it does not exist.
+func fileSize(p string) int64 {
+	if p == "" {
+		return -1
+	}
+
+	info, err := os.Stat(p)
+	if err != nil {
+		return -1
+	}
+
+	return info.Size()
+}
+
+// caseInsensitive returns true if the filesystem containing the nearest existing ancestor
+// of p treats names that differ only by case as the same. This is checked without writing
+// anything by looking up an existing name with its case swapped. If no existing name has
+// any letters to swap, the filesystem is assumed to be case-sensitive.
+func caseInsensitive(p string) bool {
+	dir := filepath.Clean(p)
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			break
+		}
+
+		if dir == filepath.Dir(dir) {
+			return false
+		}
+
+		dir = filepath.Dir(dir)
+	}
+
+	if entries, err := os.ReadDir(dir); err == nil {
+		for _, e := range entries {
+			if fold, ok := foldsCase(filepath.Join(dir, e.Name())); ok {
+				return fold
+			}
+		}
+	}
+
+	for ; dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if fold, ok := foldsCase(dir); ok {
+			return fold
+		}
+	}
+
+	return false
+}
+
+// foldsCase looks up the existing file p with the case of its base name swapped and
+// returns true if it finds the same file. The second return value is false if the base
+// name has no letters with case or p can't be read.
+func foldsCase(p string) (bool, bool) {
+	base := filepath.Base(p)
+	swapped := strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+
+		return unicode.ToUpper(r)
+	}, base)
+	if swapped == base {
+		return false, false
+	}
+
+	info, err := os.Lstat(p)
+	if err != nil {
+		return false, false
+	}
+
+	other, err := os.Lstat(filepath.Join(filepath.Dir(p), swapped))
+	if err != nil {
+		return false, true
+	}
+
+	return os.SameFile(info, other), true
+}
@@ -0,0 +1,209 @@
+package mediarename
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTvRenamer_GenerateNamesConflicts(t *testing.T) {
+	setup := func(t *testing.T) (string, []string, string) {
+		dir := t.TempDir()
+		files := []string{
+			filepath.Join(dir, "src", "a", "Show.S01E01.mkv"),
+			filepath.Join(dir, "src", "b", "Show.S01E01.mkv"),
+		}
+
+		writeTestFile(t, files[0], "small")
+		writeTestFile(t, files[1], "much larger")
+		return filepath.Join(dir, "dest"), files, filepath.Join(dir, "dest", "the_show", "season_01", "the_show-s01e01-pilot.mkv")
+	}
+
+	generate := func(t *testing.T, policy ConflictPolicy, files []string, dest string) ([]Rename, error) {
+		client := &fakeClient{show: testShow, episodes: testEpisodes}
+		renamer := NewTvRenamer(client, TvOptions{OnConflict: policy}, slog.New(slog.DiscardHandler))
+		return renamer.GenerateNames(mediaFiles(files...), dest, "tt1234")
+	}
+
+	t.Run("duplicate skip", func(t *testing.T) {
+		dest, files, expected := setup(t)
+		renames, err := generate(t, ConflictSkip, files, dest)
+
+		RequireNoError(t, err)
+		RequireEqual(t, 2, len(renames))
+		RequireEqual(t, expected, renames[0].New)
+		RequireEqual(t, ConflictDuplicate, renames[0].Conflict.Kind)
+		RequireEqual(t, files[1], renames[0].Conflict.With)
+		RequireEqual(t, true, renames[0].Skip)
+		RequireEqual(t, ConflictDuplicate, renames[1].Conflict.Kind)
+		RequireEqual(t, files[0], renames[1].Conflict.With)
+		RequireEqual(t, true, renames[1].Skip)
+	})
+
+	t.Run("duplicate fail", func(t *testing.T) {
+		dest, files, _ := setup(t)
+		_, err := generate(t, ConflictFail, files, dest)
+
+		RequireErrorIs(t, err, ErrConflict)
+	})
+
+	t.Run("duplicate suffix", func(t *testing.T) {
+		dest, files, expected := setup(t)
+		renames, err := generate(t, ConflictSuffix, files, dest)
+
+		RequireNoError(t, err)
+		RequireEqual(t, expected, renames[0].New)
+		RequireEqual(t, filepath.Join(filepath.Dir(expected), "the_show-s01e01-pilot-2.mkv"), renames[1].New)
+		RequireEqual(t, false, renames[1].Skip)
+	})
+
+	t.Run("duplicate keep larger", func(t *testing.T) {
+		dest, files, _ := setup(t)
+		renames, err := generate(t, ConflictKeepLarger, files, dest)
+
+		RequireNoError(t, err)
+		RequireEqual(t, true, renames[0].Skip)
+		RequireEqual(t, false, renames[1].Skip)
+	})
+
+	t.Run("existing skip", func(t *testing.T) {
+		dest, files, expected := setup(t)
+		writeTestFile(t, expected, "existing")
+		renames, err := generate(t, ConflictSkip, files[:1], dest)
+
+		RequireNoError(t, err)
+		RequireEqual(t, ConflictExists, renames[0].Conflict.Kind)
+		RequireEqual(t, expected, renames[0].Conflict.With)
+		RequireEqual(t, true, renames[0].Skip)
+	})
+
+	t.Run("existing overwrite", func(t *testing.T) {
+		dest, files, expected := setup(t)
+		writeTestFile(t, expected, "existing")
+		renames, err := generate(t, ConflictOverwrite, files[:1], dest)
+
+		RequireNoError(t, err)
+		RequireEqual(t, false, renames[0].Skip)
+		RequireEqual(t, true, renames[0].Overwrite)
+
+		renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true, Mode: ModeHardlink}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.RenameFiles(renames))
+		RequireEqual(t, "small", readTestFile(t, expected))
+	})
+
+	t.Run("existing keep larger", func(t *testing.T) {
+		dest, files, expected := setup(t)
+		writeTestFile(t, expected, "existing")
+		renames, err := generate(t, ConflictKeepLarger, files, dest)
+
+		RequireNoError(t, err)
+		RequireEqual(t, true, renames[0].Skip)
+		RequireEqual(t, false, renames[1].Skip)
+		RequireEqual(t, true, renames[1].Overwrite)
+	})
+
+	t.Run("already in place", func(t *testing.T) {
+		dest, _, expected := setup(t)
+		writeTestFile(t, expected, "existing")
+		renames, err := generate(t, ConflictFail, []string{expected}, dest)
+
+		RequireNoError(t, err)
+		RequireEqual(t, true, renames[0].Skip)
+		RequireEqual(t, true, renames[0].Conflict == nil)
+	})
+}
+
+func TestTvRenamer_GenerateNamesCompanionConflicts(t *testing.T) {
+	setup := func(t *testing.T) (string, []string, string) {
+		dir := t.TempDir()
+		files := []string{
+			filepath.Join(dir, "src", "Show.S01E01.mkv"),
+			filepath.Join(dir, "src", "Show.S01E01.en.srt"),
+			filepath.Join(dir, "src", "Show.S01E01.eng.srt"),
+		}
+
+		for _, f := range files {
+			writeTestFile(t, f, "subtitles")
+		}
+
+		subtitle := filepath.Join(dir, "dest", "the_show", "season_01", "the_show-s01e01-pilot.en.srt")
+		writeTestFile(t, subtitle, "existing")
+		return filepath.Join(dir, "dest"), files, subtitle
+	}
+
+	generate := func(t *testing.T, policy ConflictPolicy, files []string, dest string) ([]Rename, error) {
+		client := &fakeClient{show: testShow, episodes: testEpisodes}
+		renamer := NewTvRenamer(client, TvOptions{OnConflict: policy}, slog.New(slog.DiscardHandler))
+		return renamer.GenerateNames(mediaFiles(files...), dest, "tt1234")
+	}
+
+	t.Run("skip", func(t *testing.T) {
+		dest, files, subtitle := setup(t)
+		renames, err := generate(t, ConflictSkip, files, dest)
+
+		RequireNoError(t, err)
+		RequireEqual(t, 1, len(renames))
+		RequireEqual(t, false, renames[0].Skip)
+		RequireEqual(t, 2, len(renames[0].Companions))
+		RequireEqual(t, ConflictExists, renames[0].Companions[0].Conflict.Kind)
+		RequireEqual(t, subtitle, renames[0].Companions[0].Conflict.With)
+		RequireEqual(t, true, renames[0].Companions[0].Skip)
+		RequireEqual(t, true, renames[0].Companions[1].Skip)
+		RequireEqual(t, 1, len(renames[0].group()))
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		dest, files, subtitle := setup(t)
+		renames, err := generate(t, ConflictOverwrite, files, dest)
+
+		RequireNoError(t, err)
+		RequireEqual(t, false, renames[0].Overwrite)
+		RequireEqual(t, true, renames[0].Companions[0].Overwrite)
+		RequireEqual(t, ConflictDuplicate, renames[0].Companions[1].Conflict.Kind)
+		RequireEqual(t, true, renames[0].Companions[1].Skip)
+
+		renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.RenameFiles(renames))
+		RequireEqual(t, "subtitles", readTestFile(t, subtitle))
+		RequireEqual(t, "subtitles", readTestFile(t, files[2]))
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		dest, files, _ := setup(t)
+		_, err := generate(t, ConflictFail, files, dest)
+
+		RequireErrorIs(t, err, ErrConflict)
+	})
+}
+
+func TestTvRenamer_RenameFilesRefusesOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src", "Show.S01E01.mkv")
+	dst := filepath.Join(dir, "dest", "the_show-s01e01-pilot.mkv")
+	writeTestFile(t, src, "episode 1")
+	writeTestFile(t, dst, "existing")
+
+	renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true}, slog.New(slog.DiscardHandler))
+	RequireErrorIs(t, renamer.RenameFiles([]Rename{{Old: src, New: dst}}), ErrConflict)
+	RequireEqual(t, "existing", readTestFile(t, dst))
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "Show.S01E01.mkv"), "pilot")
+
+	t.Run("existing", func(t *testing.T) {
+		RequireEqual(t, false, caseInsensitive(dir))
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		RequireEqual(t, false, caseInsensitive(filepath.Join(dir, "dest", "the_show")))
+	})
+
+	t.Run("nothing written", func(t *testing.T) {
+		entries, err := os.ReadDir(dir)
+		RequireNoError(t, err)
+		RequireEqual(t, 1, len(entries))
+	})
+}
@@ -0,0 +1,157 @@
+package mediarename
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ExtraKind is a kind of bonus content that isn't an episode, such as a trailer. Each
+// kind is the name of the folder media servers expect it to be in.
+type ExtraKind string
+
+const (
+	// ExtraFeaturette is behind the scenes footage, interviews, and other featurettes.
+	ExtraFeaturette ExtraKind = "Featurettes"
+	// ExtraDeletedScene is scenes cut from episodes.
+	ExtraDeletedScene ExtraKind = "Deleted Scenes"
+	// ExtraTrailer is trailers, teasers, and promos.
+	ExtraTrailer ExtraKind = "Trailers"
+)
+
+var (
+	// extraKeywords are words in file and directory names that mark extras of each
+	// kind, in the order they are checked.
+	extraKeywords = []struct {
+		kind  ExtraKind
+		words []string
+	}{
+		{ExtraDeletedScene, []string{"deleted", "deleted scene", "deleted scenes"}},
+		{ExtraTrailer, []string{"trailer", "trailers", "teaser", "teasers", "promo", "promos"}},
+		{ExtraFeaturette, []string{"featurette", "featurettes", "behind the scenes", "bts", "making of", "interview", "interviews", "extra", "extras", "bonus"}},
+	}
+
+	extraWordRegex   = regexp.MustCompile(`[^\pL\pN]+`)
+	extraSeasonRegex = regexp.MustCompile(`(?i)\b(?:s|season[ ._-]?)(\d{1,2})\b`)
+)
+
+// ClassifyExtra returns the kind of extra a file is, based on keywords in its name or
+// the names of the two directories it's in, and false if it doesn't look like an extra.
+// Keywords in the file name take precedence over those in directory names.
+func ClassifyExtra(p string) (ExtraKind, bool) {
+	for _, name := range extraNames(p) {
+		words := " " + strings.ToLower(extraWordRegex.ReplaceAllString(name, " ")) + " "
+		for _, k := range extraKeywords {
+			for _, w := range k.words {
+				if strings.Contains(words, " "+w+" ") {
+					return k.kind, true
+				}
+			}
+		}
+	}
+
+	return "", false
+}
+
+// extraSeason returns the season an extra belongs to based on a season number in its
+// name or the names of the two directories it's in, and false if it belongs to the
+// whole show.
+func extraSeason(p string) (int, bool) {
+	for _, name := range extraNames(p) {
+		if m := extraSeasonRegex.FindStringSubmatch(name); m != nil {
+			n, err := strconv.Atoi(m[1])
+			if err == nil {
+				return n, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// extraNames returns the name of p without its extension followed by the names of
+// the two directories it's in, which are checked for what kind of extra it is.
+func extraNames(p string) []string {
+	names := []string{trimExt(path.Base(p))}
+	dir := path.Dir(p)
+	for i := 0; i < 2 && dir != "." && dir != "/"; i++ {
+		names = append(names, path.Base(dir))
+		dir = path.Dir(dir)
+	}
+
+	return names
+}
+
+// extraRename returns a rename of file, and its companions, to a folder for extras of
+// the given kind.
+func (r *TvRenamer) extraRename(file MediaFile, kind ExtraKind, dest string, show *Show) (Rename, error) {
+	newName, truncated, err := r.extraName(file.Path, file.Companions, kind, dest, show)
+	if err != nil {
+		return Rename{}, fmt.Errorf("unable to generate new name for %s: %w", file.Path, err)
+	}
+
+	r.logger.Info("found extra", "file", file.Path, "kind", kind)
+	op := Rename{Old: file.Path, New: newName, Truncated: truncated, Show: show, Extra: kind}
+	for _, companion := range file.Companions {
+		op.Companions = append(op.Companions, Rename{Old: companion})
+	}
+
+	return op, nil
+}
+
+// extraName returns the new name of an extra of the given kind: a folder for the kind
+// in the season directory, if the extra is for a season, or the show directory. The
+// directories are found by generating a name for an episode of the season with the
+// configured template. The extra keeps its own name, cleaned up by the sanitizer and
+// shortened if needed to fit the configured limits. Returns true if the name was shortened.
+func (r *TvRenamer) extraName(file string, companions []string, kind ExtraKind, dest string, show *Show) (string, bool, error) {
+	season, forSeason := extraSeason(file)
+	episode, _, err := r.nameFromEpisodes(file, nil, dest, show, Episodes{{Season: season, Number: 1}}, nil)
+	if err != nil {
+		return "", false, err
+	}
+
+	dir := path.Dir(episode)
+	if showPath, depth := showDir(dest, episode); !forSeason && depth > 0 {
+		dir = showPath
+	}
+
+	san := DefaultSanitizer()
+	if r.opts.Sanitizer != nil {
+		san = *r.opts.Sanitizer
+	}
+
+	stem, ext := trimExt(path.Base(file)), path.Ext(file)
+	if IsSubtitle(file) {
+		var sub Subtitle
+		stem, sub = ParseSubtitle(file)
+		ext = sub.Suffix() + ext
+	}
+
+	stem = strings.TrimSpace(strings.NewReplacer(".", " ", "_", " ").Replace(stem))
+	name := func(stem string) string {
+		return path.Join(dir, san.Segment(string(kind)), san.Segment(san.Sanitize(stem))+ext)
+	}
+
+	limits := r.limits(file, ext, companions)
+	newName := name(stem)
+	over := limits.exceeds(newName)
+	if over == 0 {
+		return newName, false, nil
+	}
+
+	// Sanitizing may change the length of the name, so shorten it and check again
+	// until it fits or there's nothing left to shorten.
+	for size := len(stem) - over; size > 0 && over > 0; size -= over {
+		newName = name(truncateBytes(stem, size))
+		over = limits.exceeds(newName)
+	}
+
+	if over > 0 {
+		return "", false, fmt.Errorf("%w: %s is %d bytes over the limit", ErrNameTooLong, newName, over)
+	}
+
+	return newName, true, nil
+}
@@ -0,0 +1,96 @@
+package mediarename
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestClassifyExtra(t *testing.T) {
+	cases := []struct {
+		path  string
+		kind  ExtraKind
+		extra bool
+	}{
+		{"src/Show.S01.Behind.the.Scenes.mkv", ExtraFeaturette, true},
+		{"src/Show - Cast Interview.mp4", ExtraFeaturette, true},
+		{"src/Show.Deleted.Scenes.720p.mkv", ExtraDeletedScene, true},
+		{"src/Show.Season.2.Trailer.mkv", ExtraTrailer, true},
+		{"src/Extras/Deleted Scenes/Dinner.mkv", ExtraDeletedScene, true},
+		{"src/Extras/Cast Table Read.mkv", ExtraFeaturette, true},
+		{"src/Show.Trailer.Park.Boys.mkv", ExtraTrailer, true},
+		{"src/Show.Bonus-Feature.mkv", ExtraFeaturette, true},
+		{"src/Show.Promotional.Material.mkv", "", false},
+		{"src/Show.S01E01.mkv", "", false},
+		{"extras/src/show/Show.Pilot.mkv", "", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			kind, ok := ClassifyExtra(c.path)
+			RequireEqual(t, c.extra, ok)
+			RequireEqual(t, c.kind, kind)
+		})
+	}
+}
+
+func TestTvRenamer_GenerateNamesExtras(t *testing.T) {
+	client := &fakeClient{show: testShow, episodes: testEpisodes}
+
+	t.Run("extras", func(t *testing.T) {
+		renamer := NewTvRenamer(client, TvOptions{Extras: true}, slog.New(slog.DiscardHandler))
+		renames, err := renamer.GenerateNames(mediaFiles(
+			"src/Show.S01E01.mkv",
+			"src/Show.S01.Behind.the.Scenes.mkv",
+			"src/Show.S01.Behind.the.Scenes.en.srt",
+			"src/Extras/Deleted Scenes/Dinner.mkv",
+			"src/Show.Trailer.mp4",
+			"src/Show.Pilot.mkv",
+		), "dest", "tt1234")
+
+		RequireNoError(t, err)
+		RequireEqual(t, 4, len(renames))
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e01-pilot.mkv", renames[0].New)
+		RequireEqual(t, "dest/the_show/season_01/Featurettes/show_s01_behind_the_scenes.mkv", renames[1].New)
+		RequireEqual(t, ExtraFeaturette, renames[1].Extra)
+		RequireEqual(t, "dest/the_show/season_01/Featurettes/show_s01_behind_the_scenes.en.srt", renames[1].Companions[0].New)
+		RequireEqual(t, "dest/the_show/Deleted Scenes/dinner.mkv", renames[2].New)
+		RequireEqual(t, "dest/the_show/Trailers/show_trailer.mp4", renames[3].New)
+	})
+
+	t.Run("season directory", func(t *testing.T) {
+		renamer := NewTvRenamer(client, TvOptions{Extras: true}, slog.New(slog.DiscardHandler))
+		renames, err := renamer.GenerateNames(mediaFiles(
+			"src/Show.S01.1080p/Featurettes/Interview.mkv",
+		), "dest", "tt1234")
+
+		RequireNoError(t, err)
+		RequireEqual(t, 1, len(renames))
+		RequireEqual(t, "dest/the_show/season_01/Featurettes/interview.mkv", renames[0].New)
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		limits := Limits{MaxName: 20}
+		renamer := NewTvRenamer(client, TvOptions{Extras: true, Limits: &limits}, slog.New(slog.DiscardHandler))
+		renames, err := renamer.GenerateNames(mediaFiles(
+			"src/Show.Behind.the.Scenes.mkv",
+			"src/Show.Behind.the.Scenes.en.sdh.srt",
+		), "dest", "tt1234")
+
+		RequireNoError(t, err)
+		RequireEqual(t, 1, len(renames))
+		RequireEqual(t, "dest/the_show/Featurettes/show_behi.mkv", renames[0].New)
+		RequireEqual(t, "dest/the_show/Featurettes/show_behi.en.sdh.srt", renames[0].Companions[0].New)
+		RequireEqual(t, true, renames[0].Truncated)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		renamer := NewTvRenamer(client, TvOptions{}, slog.New(slog.DiscardHandler))
+		renames, err := renamer.GenerateNames(mediaFiles(
+			"src/Show.S01E01.mkv",
+			"src/Show.Trailer.mp4",
+		), "dest", "tt1234")
+
+		RequireNoError(t, err)
+		RequireEqual(t, 1, len(renames))
+	})
+}
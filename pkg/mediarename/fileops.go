@@ -0,0 +1,414 @@
+package mediarename
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+var (
+	ErrVerifyFailed = errors.New("copy verification failed")
+	ErrCrossDevice  = errors.New("source and destination are on different devices")
+	ErrNoReflink    = errors.New("reflinks not supported")
+)
+
+// Mode is how files are placed at their new names.
+type Mode string
+
+const (
+	// ModeMove renames files, falling back to copying and removing them when
+	// moving between filesystems.
+	ModeMove Mode = "move"
+	// ModeCopy copies files, leaving the originals untouched.
+	ModeCopy Mode = "copy"
+	// ModeHardlink creates hard links to files, leaving the originals untouched. Hard
+	// links are only possible within a single filesystem.
+	ModeHardlink Mode = "hardlink"
+	// ModeSymlink creates symbolic links to files, leaving the originals untouched.
+	ModeSymlink Mode = "symlink"
+	// ModeMoveSymlink moves files and leaves a symbolic link to the new location
+	// in place of each original.
+	ModeMoveSymlink Mode = "move-symlink"
+	// ModeReflink creates copy-on-write clones of files, leaving the originals untouched.
+	// Clones share storage with the original until modified. Only supported on Linux
+	// filesystems such as btrfs and XFS.
+	ModeReflink Mode = "reflink"
+)
+
+// Modes returns the names of all supported modes.
+func Modes() []string {
+	return []string{
+		string(ModeMove),
+		string(ModeCopy),
+		string(ModeHardlink),
+		string(ModeSymlink),
+		string(ModeMoveSymlink),
+		string(ModeReflink),
+	}
+}
+
+// FallbackModes returns the names of modes that can be used when the requested mode
+// is not possible between filesystems. Only copying is allowed since hard links and
+// reflinks are used to leave the originals in place, for example to keep seeding them.
+func FallbackModes() []string {
+	return []string{string(ModeCopy)}
+}
+
+// copyOptions controls how files are copied.
+type copyOptions struct {
+	// verify compares the size and checksum of a copy to the original.
+	verify bool
+	// progress, if set, records all bytes written by a copy.
+	progress *Progress
+	// preserve is the attributes of the original to keep. Defaults to DefaultPreserve.
+	preserve *Preserve
+	// limiter, if set, limits the rate that bytes are written by a copy.
+	limiter *RateLimiter
+}
+
+// preserveAttrs returns the attributes of the original to keep.
+func (o copyOptions) preserveAttrs() Preserve {
+	if o.preserve == nil {
+		return DefaultPreserve()
+	}
+
+	return *o.preserve
+}
+
+// moveFile renames src to dst. If src and dst are on different filesystems, the
+// file is copied to dst, verified, and src is removed only after the copy succeeds.
+func moveFile(src string, dst string, opts copyOptions) error {
+	err := os.Rename(src, dst)
+	if err == nil && opts.progress != nil {
+		// Renamed files weren't copied so they don't count towards throughput
+		if info, err := os.Lstat(dst); err == nil {
+			opts.progress.SkipBytes(info.Size())
+		}
+	}
+
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	opts.verify = true
+	if err := copyFile(src, dst, opts); err != nil {
+		return fmt.Errorf("unable to copy across devices: %w", err)
+	}
+
+	if err := os.Remove(src); err != nil {
+		return fmt.Errorf("unable to remove %s after copy: %w", src, err)
+	}
+
+	return nil
+}
+
+// linkFile creates a hard link to src at dst. If src and dst are on different filesystems,
+// an error wrapping ErrCrossDevice is returned.
+func linkFile(src string, dst string) error {
+	err := os.Link(src, dst)
+	if errors.Is(err, syscall.EXDEV) {
+		return fmt.Errorf("%w: %w", ErrCrossDevice, err)
+	}
+
+	return err
+}
+
+// symlinkFile creates a symbolic link at dst pointing to src. If relative is true, the
+// link target is relative to the directory containing dst, otherwise it is absolute.
+func symlinkFile(src string, dst string, relative bool) error {
+	target, err := linkTarget(src, dst, relative)
+	if err != nil {
+		return err
+	}
+
+	return os.Symlink(target, dst)
+}
+
+// linkTarget returns the target of a symbolic link at dst pointing to src. If relative is
+// true, the target is relative to the directory containing dst, otherwise it is absolute.
+func linkTarget(src string, dst string, relative bool) (string, error) {
+	target, err := filepath.Abs(src)
+	if err != nil || !relative {
+		return target, err
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(dst))
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Rel(dir, target)
+}
+
+// moveAndSymlinkFile moves src to dst and creates a symbolic link at src pointing to
+// the new location of the file.
+func moveAndSymlinkFile(src string, dst string, relative bool, opts copyOptions) error {
+	if err := moveFile(src, dst, opts); err != nil {
+		return err
+	}
+
+	if err := symlinkFile(dst, src, relative); err != nil {
+		return fmt.Errorf("unable to link %s to new location: %w", src, err)
+	}
+
+	return nil
+}
+
+// danglingLinks returns all symbolic links under base whose targets do not exist.
+func danglingLinks(base string) ([]string, error) {
+	var out []string
+	err := filepath.WalkDir(base, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+
+		if _, err := os.Stat(p); errors.Is(err, fs.ErrNotExist) {
+			out = append(out, p)
+		}
+
+		return nil
+	})
+
+	return out, err
+}
+
+// reflinkFile creates a copy-on-write clone of src at dst by cloning into a temporary file
+// in the same directory as dst and renaming it into place. Permissions and timestamps of
+// src are preserved. If the filesystem does not support cloning, an error wrapping
+// ErrNoReflink is returned.
+func reflinkFile(src string, dst string, opts copyOptions) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = in.Close() }()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := createTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".", ".tmp")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = cloneFile(tmp, in); err != nil {
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if err = preserveAttributes(src, tmp.Name(), info, opts.preserveAttrs()); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp.Name(), dst); err != nil {
+		return err
+	}
+
+	if opts.progress != nil {
+		opts.progress.AddBytes(info.Size())
+	}
+
+	return syncDir(filepath.Dir(dst))
+}
+
+// createTemp creates a new file in dir, named prefix followed by a random number and suffix,
+// and opens it for writing. Unlike os.CreateTemp, the file is created with the same
+// permissions as any other new file, 0666 less the umask, since it is renamed into place
+// and kept if attributes of the original aren't preserved.
+func createTemp(dir string, prefix string, suffix string) (*os.File, error) {
+	for try := 0; ; try++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10)+suffix)
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if errors.Is(err, fs.ErrExist) && try < 10000 {
+			continue
+		}
+
+		return f, err
+	}
+}
+
+// writeFile atomically writes contents to p, replacing any existing file.
+func writeFile(p string, contents []byte) error {
+	dir := filepath.Dir(p)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(p)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file for %s: %w", p, err)
+	}
+
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(contents); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("unable to write %s: %w", p, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to write %s: %w", p, err)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("unable to set permissions of %s: %w", p, err)
+	}
+
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return fmt.Errorf("unable to write %s: %w", p, err)
+	}
+
+	return nil
+}
+
+// copyFile copies src to dst by writing to a temporary file in the same directory as
+// dst and renaming it into place once the contents have been synced to disk. Attributes
+// of src are preserved according to opts. If verification is enabled, the size and
+// SHA-256 checksum of the written file are compared to src before it is renamed into place.
+func copyFile(src string, dst string, opts copyOptions) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = in.Close() }()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := createTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".", ".tmp")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	var w io.Writer = tmp
+	if opts.limiter != nil {
+		w = limitedWriter{w: w, limiter: opts.limiter}
+	}
+
+	if opts.progress != nil {
+		file := opts.progress.StartFile(filepath.Base(dst), info.Size())
+		defer file.Finish()
+		w = io.MultiWriter(w, file)
+	}
+
+	h := sha256.New()
+	if _, err = io.Copy(w, io.TeeReader(in, h)); err != nil {
+		return err
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if opts.verify {
+		if err = verifyFile(tmp.Name(), info.Size(), h); err != nil {
+			return err
+		}
+	}
+
+	if err = preserveAttributes(src, tmp.Name(), info, opts.preserveAttrs()); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp.Name(), dst); err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(dst))
+}
+
+// verifyFile checks that the file at p has the expected size and a SHA-256 checksum
+// matching the sum of expected.
+func verifyFile(p string, size int64, expected hash.Hash) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+
+	if n != size {
+		return fmt.Errorf("%w: expected %d bytes, wrote %d bytes", ErrVerifyFailed, size, n)
+	}
+
+	if !bytes.Equal(h.Sum(nil), expected.Sum(nil)) {
+		return fmt.Errorf("%w: checksum mismatch", ErrVerifyFailed)
+	}
+
+	return nil
+}
+
+// syncDir flushes directory entries for dir to disk so that a rename into it is durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = d.Close() }()
+
+	// Some filesystems don't support syncing directories, this is best-effort.
+	_ = d.Sync()
+	return nil
+}
+
+// sameDevice returns true if src and the nearest existing ancestor of dst are on the
+// same filesystem. If this cannot be determined, true is returned.
+func sameDevice(src string, dst string) bool {
+	srcDev, ok := deviceID(src)
+	if !ok {
+		return true
+	}
+
+	for p := dst; ; p = filepath.Dir(p) {
+		if dstDev, ok := deviceID(p); ok {
+			return srcDev == dstDev
+		}
+
+		if p == filepath.Dir(p) {
+			return true
+		}
+	}
+}
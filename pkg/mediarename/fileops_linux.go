@@ -0,0 +1,109 @@
+package mediarename
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ficlone is the FICLONE ioctl request from linux/fs.h.
+const ficlone = 0x40049409
+
+// cloneFile makes dst a copy-on-write clone of src using the FICLONE ioctl.
+func cloneFile(dst *os.File, src *os.File) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), ficlone, src.Fd())
+	switch {
+	case errno == 0:
+		return nil
+	case errors.Is(errno, syscall.EXDEV):
+		return fmt.Errorf("%w: %w: %w", ErrNoReflink, ErrCrossDevice, errno)
+	case errors.Is(errno, syscall.EOPNOTSUPP), errors.Is(errno, syscall.ENOTTY), errors.Is(errno, syscall.EINVAL), errors.Is(errno, syscall.ENOSYS):
+		return fmt.Errorf("%w: %w", ErrNoReflink, errno)
+	default:
+		return errno
+	}
+}
+
+// fileAtime returns the access time of a file, falling back to the modification time
+// if it is not available.
+func fileAtime(info os.FileInfo) time.Time {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(st.Atim.Sec, st.Atim.Nsec)
+	}
+
+	return info.ModTime()
+}
+
+// fileInode returns the inode number of a file or zero if it is not available.
+func fileInode(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Ino
+	}
+
+	return 0
+}
+
+// preserveOwner changes the owner of p to the user and group of info.
+func preserveOwner(p string, info os.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	return os.Lchown(p, int(st.Uid), int(st.Gid))
+}
+
+// copyXattrs copies extended attributes in the "user" namespace from src to dst. Other
+// namespaces require privileges or are specific to the filesystem. Nothing is copied if
+// either filesystem doesn't support extended attributes.
+func copyXattrs(src string, dst string) error {
+	size, err := syscall.Listxattr(src, nil)
+	if errors.Is(err, syscall.ENOTSUP) || size == 0 {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	buf := make([]byte, size)
+	if size, err = syscall.Listxattr(src, buf); err != nil {
+		return err
+	}
+
+	for _, name := range strings.Split(string(buf[:size]), "\x00") {
+		if !strings.HasPrefix(name, "user.") {
+			continue
+		}
+
+		n, err := syscall.Getxattr(src, name, nil)
+		if err != nil {
+			return err
+		}
+
+		val := make([]byte, n)
+		if n, err = syscall.Getxattr(src, name, val); err != nil {
+			return err
+		}
+
+		err = syscall.Setxattr(dst, name, val[:n], 0)
+		if errors.Is(err, syscall.ENOTSUP) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("unable to set %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// deviceID returns the ID of the device containing p, if it exists.
+func deviceID(p string) (uint64, bool) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(p, &st); err != nil {
+		return 0, false
+	}
+
+	return st.Dev, true
+}
@@ -0,0 +1,39 @@
+//go:build !linux
+
+package mediarename
+
+import (
+	"os"
+	"time"
+)
+
+// fileAtime returns the modification time of a file since access time is not
+// available on this platform.
+func fileAtime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
+
+// fileInode is not supported on this platform.
+func fileInode(os.FileInfo) uint64 {
+	return 0
+}
+
+// cloneFile is not supported on this platform.
+func cloneFile(*os.File, *os.File) error {
+	return ErrNoReflink
+}
+
+// preserveOwner is not supported on this platform.
+func preserveOwner(string, os.FileInfo) error {
+	return nil
+}
+
+// copyXattrs is not supported on this platform.
+func copyXattrs(string, string) error {
+	return nil
+}
+
+// deviceID is not supported on this platform.
+func deviceID(string) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,103 @@
+package mediarename
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, p string, contents string) {
+	t.Helper()
+	RequireNoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+	RequireNoError(t, os.WriteFile(p, []byte(contents), 0644))
+}
+
+func readTestFile(t *testing.T, p string) string {
+	t.Helper()
+	b, err := os.ReadFile(p)
+	RequireNoError(t, err)
+	return string(b)
+}
+
+func TestCopyFile(t *testing.T) {
+	t.Run("preserves contents and attributes", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src.mkv")
+		dst := filepath.Join(dir, "dst.mkv")
+		mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		writeTestFile(t, src, "some video")
+		RequireNoError(t, os.Chmod(src, 0640))
+		RequireNoError(t, os.Chtimes(src, mtime, mtime))
+		RequireNoError(t, copyFile(src, dst, copyOptions{verify: true}))
+
+		info, err := os.Stat(dst)
+		RequireNoError(t, err)
+		RequireEqual(t, "some video", readTestFile(t, dst))
+		RequireEqual(t, os.FileMode(0640), info.Mode().Perm())
+		RequireEqual(t, true, mtime.Equal(info.ModTime()))
+		RequireEqual(t, "some video", readTestFile(t, src))
+	})
+
+	t.Run("missing source", func(t *testing.T) {
+		dir := t.TempDir()
+		err := copyFile(filepath.Join(dir, "missing.mkv"), filepath.Join(dir, "dst.mkv"), copyOptions{verify: true})
+
+		RequireErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestMoveFile(t *testing.T) {
+	t.Run("same device", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src.mkv")
+		dst := filepath.Join(dir, "dst.mkv")
+
+		writeTestFile(t, src, "some video")
+		RequireNoError(t, moveFile(src, dst, copyOptions{}))
+
+		_, err := os.Stat(src)
+		RequireErrorIs(t, err, os.ErrNotExist)
+		RequireEqual(t, "some video", readTestFile(t, dst))
+	})
+
+	t.Run("cross device", func(t *testing.T) {
+		src := filepath.Join(t.TempDir(), "src.mkv")
+		writeTestFile(t, src, "some video")
+
+		other, err := os.MkdirTemp("/dev/shm", "mediarename")
+		if err != nil {
+			t.Skip("no tmpfs available for cross device test")
+		}
+
+		t.Cleanup(func() { _ = os.RemoveAll(other) })
+		if sameDevice(src, other) {
+			t.Skip("no second filesystem available for cross device test")
+		}
+
+		dst := filepath.Join(other, "dst.mkv")
+		RequireNoError(t, moveFile(src, dst, copyOptions{}))
+
+		_, err = os.Stat(src)
+		RequireErrorIs(t, err, os.ErrNotExist)
+		RequireEqual(t, "some video", readTestFile(t, dst))
+	})
+}
+
+func TestReflinkFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.mkv")
+	dst := filepath.Join(dir, "dst.mkv")
+	writeTestFile(t, src, "some video")
+
+	err := reflinkFile(src, dst, copyOptions{})
+	if errors.Is(err, ErrNoReflink) {
+		t.Skipf("reflinks not supported by filesystem: %s", err)
+	}
+
+	RequireNoError(t, err)
+	RequireEqual(t, "some video", readTestFile(t, dst))
+	RequireEqual(t, "some video", readTestFile(t, src))
+}
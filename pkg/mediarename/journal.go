@@ -0,0 +1,356 @@
+package mediarename
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoJournal = errors.New("no journal found")
+	ErrChanged   = errors.New("file changed since it was renamed")
+)
+
+const (
+	journalPrefix = "journal-"
+	journalSuffix = ".jsonl"
+)
+
+// JournalEntry is a record of a single file that was renamed, copied, or linked.
+type JournalEntry struct {
+	Old       string    `json:"old"`
+	New       string    `json:"new"`
+	Mode      Mode      `json:"mode"`
+	Overwrite bool      `json:"overwrite,omitempty"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"mtime"`
+	Inode     uint64    `json:"inode,omitempty"`
+	SHA256    string    `json:"sha256,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Journal is an append-only file of JSON lines recording each operation performed
+// on a file so that they can be undone later. It is safe for concurrent use.
+type Journal struct {
+	mu      sync.Mutex
+	file    *os.File
+	entries int
+}
+
+// DefaultStateDir returns the directory used for journals when one isn't specified:
+// $XDG_STATE_HOME/mediarename or ~/.local/state/mediarename.
+func DefaultStateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "mediarename")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "mediarename")
+	}
+
+	return filepath.Join(home, ".local", "state", "mediarename")
+}
+
+// NewJournal creates a new journal file in dir, named for the current time.
+func NewJournal(dir string) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("unable to create state directory %s: %w", dir, err)
+	}
+
+	name := journalPrefix + time.Now().UTC().Format("20060102T150405.000000000Z") + journalSuffix
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create journal: %w", err)
+	}
+
+	return &Journal{file: f}, nil
+}
+
+// Path returns the path of the journal file.
+func (j *Journal) Path() string {
+	return j.file.Name()
+}
+
+// Record appends an entry for op, performed using mode, to the journal and syncs it
+// to disk. Names are recorded as absolute paths so that the journal can be undone from
+// any directory. The size, modification time, and inode of the file at the new name of op are
+// recorded so that changes to it can be detected before undoing. Copies are hashed as
+// well since they are new files that could be replaced by anything of the same size.
+func (j *Journal) Record(op Rename, mode Mode) error {
+	entry := JournalEntry{
+		Old:       absPath(op.Old),
+		New:       absPath(op.New),
+		Mode:      mode,
+		Overwrite: op.Overwrite,
+		Time:      time.Now().UTC(),
+	}
+
+	switch mode {
+	case ModeSymlink:
+	case ModeCopy, ModeReflink:
+		info, sum, err := hashFile(op.New)
+		if err != nil {
+			return fmt.Errorf("unable to hash %s for journal: %w", op.New, err)
+		}
+
+		entry.Size = info.Size()
+		entry.ModTime = info.ModTime().UTC()
+		entry.SHA256 = sum
+	default:
+		info, err := os.Stat(op.New)
+		if err != nil {
+			return fmt.Errorf("unable to stat %s for journal: %w", op.New, err)
+		}
+
+		entry.Size = info.Size()
+		entry.ModTime = info.ModTime().UTC()
+		entry.Inode = fileInode(info)
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("unable to serialize journal entry: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.file.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("unable to write journal entry: %w", err)
+	}
+
+	j.entries++
+	return j.file.Sync()
+}
+
+// Close closes the journal file. If nothing was recorded the file is removed so that
+// it isn't picked as the latest journal to undo.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.file.Close(); err != nil {
+		return err
+	}
+
+	if j.entries == 0 {
+		return os.Remove(j.file.Name())
+	}
+
+	return nil
+}
+
+// ReadJournal reads all entries from the journal file at p.
+func ReadJournal(p string) ([]JournalEntry, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open journal: %w", err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	var out []JournalEntry
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("unable to parse journal %s line %d: %w", p, line, err)
+		}
+
+		out = append(out, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read journal: %w", err)
+	}
+
+	return out, nil
+}
+
+// LatestJournal returns the path of the most recent journal in dir.
+func LatestJournal(dir string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, journalPrefix+"*"+journalSuffix))
+	if err != nil {
+		return "", err
+	}
+
+	if len(matches) == 0 {
+		return "", fmt.Errorf("%w in %s", ErrNoJournal, dir)
+	}
+
+	// Journal names are timestamps so the last one lexically is the most recent
+	slices.Sort(matches)
+	return matches[len(matches)-1], nil
+}
+
+// Undoer reverses operations recorded in a journal.
+type Undoer struct {
+	commit bool
+	logger *slog.Logger
+}
+
+func NewUndoer(commit bool, logger *slog.Logger) *Undoer {
+	return &Undoer{commit: commit, logger: logger}
+}
+
+// Undo reverses each entry, most recent first. Moved files are moved back to their
+// original names and copies or links are removed. Entries whose files have changed
+// since they were recorded are skipped, logged, and returned.
+func (u *Undoer) Undo(entries []JournalEntry) ([]JournalEntry, error) {
+	var skipped []JournalEntry
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if err := u.check(e); err != nil {
+			u.logger.Warn("skipping changed file", "old", e.Old, "new", e.New, "mode", e.Mode, "err", err)
+			skipped = append(skipped, e)
+			continue
+		}
+
+		u.logger.Info("undo", "old", e.Old, "new", e.New, "mode", e.Mode)
+		if e.Overwrite {
+			u.logger.Warn("file that was overwritten cannot be restored", "new", e.New)
+		}
+
+		if u.commit {
+			if err := undoEntry(e); err != nil {
+				return skipped, err
+			}
+		}
+	}
+
+	return skipped, nil
+}
+
+// check returns an error wrapping ErrChanged if the files for an entry are no longer
+// in the state they were left in.
+func (u *Undoer) check(e JournalEntry) error {
+	if e.Mode == ModeSymlink {
+		target, err := os.Readlink(e.New)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrChanged, err)
+		}
+
+		if resolveLink(e.New, target) != absPath(e.Old) {
+			return fmt.Errorf("%w: link points to %s", ErrChanged, target)
+		}
+
+		return nil
+	}
+
+	if _, err := os.Lstat(e.Old); err == nil && e.Mode == ModeMove {
+		return fmt.Errorf("%w: %s exists again", ErrChanged, e.Old)
+	}
+
+	if e.SHA256 != "" {
+		info, sum, err := hashFile(e.New)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrChanged, err)
+		}
+
+		if info.Size() != e.Size || sum != e.SHA256 {
+			return fmt.Errorf("%w: contents differ", ErrChanged)
+		}
+
+		return nil
+	}
+
+	info, err := os.Stat(e.New)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrChanged, err)
+	}
+
+	if info.Size() != e.Size || !info.ModTime().Equal(e.ModTime) || fileInode(info) != e.Inode {
+		return fmt.Errorf("%w: size, modification time, or inode differ", ErrChanged)
+	}
+
+	return nil
+}
+
+// undoEntry reverses a single operation, moving a file back to its original name or
+// removing a copy or link.
+func undoEntry(e JournalEntry) error {
+	switch e.Mode {
+	case ModeMove:
+		if err := os.MkdirAll(filepath.Dir(e.Old), 0755); err != nil {
+			return fmt.Errorf("unable to create parent directory for %s: %w", e.Old, err)
+		}
+
+		if err := moveFile(e.New, e.Old, copyOptions{}); err != nil {
+			return fmt.Errorf("unable to move %s back to %s: %w", e.New, e.Old, err)
+		}
+	case ModeMoveSymlink:
+		if info, err := os.Lstat(e.Old); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if err := os.Remove(e.Old); err != nil {
+				return fmt.Errorf("unable to remove link %s: %w", e.Old, err)
+			}
+		}
+
+		if err := moveFile(e.New, e.Old, copyOptions{}); err != nil {
+			return fmt.Errorf("unable to move %s back to %s: %w", e.New, e.Old, err)
+		}
+	default:
+		if err := os.Remove(e.New); err != nil {
+			return fmt.Errorf("unable to remove %s: %w", e.New, err)
+		}
+	}
+
+	return nil
+}
+
+// hashFile returns information about the file at p and the hex encoded SHA-256
+// checksum of its contents.
+func hashFile(p string) (os.FileInfo, string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, "", err
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, "", err
+	}
+
+	return info, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// resolveLink returns the absolute path that a symbolic link at p with the given
+// target points to.
+func resolveLink(p string, target string) string {
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(p), target)
+	}
+
+	return absPath(target)
+}
+
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+
+	return filepath.Clean(p)
+}
@@ -0,0 +1,149 @@
+package mediarename
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func renameWithJournal(t *testing.T, mode Mode, renames []Rename) (string, []JournalEntry) {
+	t.Helper()
+	state := filepath.Join(t.TempDir(), "state")
+	opts := TvOptions{Commit: true, Mode: mode, JournalDir: state}
+	renamer := NewTvRenamer(&fakeClient{}, opts, slog.New(slog.DiscardHandler))
+	RequireNoError(t, renamer.RenameFiles(renames))
+
+	journal, err := LatestJournal(state)
+	RequireNoError(t, err)
+	entries, err := ReadJournal(journal)
+	RequireNoError(t, err)
+	return journal, entries
+}
+
+func TestJournal(t *testing.T) {
+	t.Run("records entries", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src", "Show.S01E01.mkv")
+		dst := filepath.Join(dir, "dest", "the_show-s01e01-pilot.mkv")
+		writeTestFile(t, src, "episode 1")
+
+		_, entries := renameWithJournal(t, ModeMove, []Rename{{Old: src, New: dst}})
+
+		RequireEqual(t, 1, len(entries))
+		RequireEqual(t, src, entries[0].Old)
+		RequireEqual(t, dst, entries[0].New)
+		RequireEqual(t, ModeMove, entries[0].Mode)
+		RequireEqual(t, int64(9), entries[0].Size)
+		RequireEqual(t, "", entries[0].SHA256)
+	})
+
+	t.Run("hashes copies", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src", "Show.S01E01.mkv")
+		dst := filepath.Join(dir, "dest", "the_show-s01e01-pilot.mkv")
+		writeTestFile(t, src, "episode 1")
+
+		_, entries := renameWithJournal(t, ModeCopy, []Rename{{Old: src, New: dst}})
+
+		RequireEqual(t, 1, len(entries))
+		RequireEqual(t, int64(9), entries[0].Size)
+		RequireEqual(t, "ed9cab6604c909b25131595f2b5ba12583c1be89a230e9e2a5358381e2664e96", entries[0].SHA256)
+	})
+
+	t.Run("records absolute paths", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestFile(t, filepath.Join(dir, "src", "Show.S01E01.mkv"), "episode 1")
+		t.Chdir(dir)
+
+		_, entries := renameWithJournal(t, ModeMove, []Rename{{Old: "src/Show.S01E01.mkv", New: "dest/the_show-s01e01-pilot.mkv"}})
+
+		RequireEqual(t, 1, len(entries))
+		RequireEqual(t, filepath.Join(dir, "src", "Show.S01E01.mkv"), entries[0].Old)
+		RequireEqual(t, filepath.Join(dir, "dest", "the_show-s01e01-pilot.mkv"), entries[0].New)
+	})
+
+	t.Run("nothing renamed", func(t *testing.T) {
+		state := filepath.Join(t.TempDir(), "state")
+		opts := TvOptions{Commit: true, JournalDir: state}
+		renamer := NewTvRenamer(&fakeClient{}, opts, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.RenameFiles([]Rename{{Old: "src/Show.S01E01.mkv", New: "dest/pilot.mkv", Skip: true}}))
+
+		_, err := LatestJournal(state)
+		RequireErrorIs(t, err, ErrNoJournal)
+	})
+
+	t.Run("no journal", func(t *testing.T) {
+		_, err := LatestJournal(t.TempDir())
+
+		RequireErrorIs(t, err, ErrNoJournal)
+	})
+}
+
+func TestUndoer_Undo(t *testing.T) {
+	t.Run("move", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src", "Show.S01E01.mkv")
+		dst := filepath.Join(dir, "dest", "the_show-s01e01-pilot.mkv")
+		writeTestFile(t, src, "episode 1")
+
+		_, entries := renameWithJournal(t, ModeMove, []Rename{{Old: src, New: dst}})
+		skipped, err := NewUndoer(true, slog.New(slog.DiscardHandler)).Undo(entries)
+
+		RequireNoError(t, err)
+		RequireEqual(t, 0, len(skipped))
+		RequireEqual(t, "episode 1", readTestFile(t, src))
+		_, err = os.Stat(dst)
+		RequireErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src", "Show.S01E01.mkv")
+		dst := filepath.Join(dir, "dest", "the_show-s01e01-pilot.mkv")
+		writeTestFile(t, src, "episode 1")
+
+		_, entries := renameWithJournal(t, ModeMove, []Rename{{Old: src, New: dst}})
+		_, err := NewUndoer(false, slog.New(slog.DiscardHandler)).Undo(entries)
+
+		RequireNoError(t, err)
+		RequireEqual(t, "episode 1", readTestFile(t, dst))
+	})
+
+	t.Run("copy and symlink", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src", "Show.S01E01.mkv")
+		copied := filepath.Join(dir, "copy", "the_show-s01e01-pilot.mkv")
+		linked := filepath.Join(dir, "link", "the_show-s01e01-pilot.mkv")
+		writeTestFile(t, src, "episode 1")
+
+		_, copies := renameWithJournal(t, ModeCopy, []Rename{{Old: src, New: copied}})
+		_, links := renameWithJournal(t, ModeSymlink, []Rename{{Old: src, New: linked}})
+		skipped, err := NewUndoer(true, slog.New(slog.DiscardHandler)).Undo(append(copies, links...))
+
+		RequireNoError(t, err)
+		RequireEqual(t, 0, len(skipped))
+		RequireEqual(t, "episode 1", readTestFile(t, src))
+		_, err = os.Lstat(copied)
+		RequireErrorIs(t, err, os.ErrNotExist)
+		_, err = os.Lstat(linked)
+		RequireErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("changed file skipped", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src", "Show.S01E01.mkv")
+		dst := filepath.Join(dir, "dest", "the_show-s01e01-pilot.mkv")
+		writeTestFile(t, src, "episode 1")
+
+		_, entries := renameWithJournal(t, ModeMove, []Rename{{Old: src, New: dst}})
+		writeTestFile(t, dst, "episode 1 edited")
+		skipped, err := NewUndoer(true, slog.New(slog.DiscardHandler)).Undo(entries)
+
+		RequireNoError(t, err)
+		RequireEqual(t, 1, len(skipped))
+		RequireEqual(t, "episode 1 edited", readTestFile(t, dst))
+		_, err = os.Stat(src)
+		RequireErrorIs(t, err, os.ErrNotExist)
+	})
+}
@@ -0,0 +1,89 @@
+package mediarename
+
+import (
+	"strings"
+)
+
+var (
+	// languageCountries maps ISO 639-1 language codes to the ISO 3166-1 country codes
+	// where that language is primarily spoken. This is used to pick alternate titles
+	// from providers that only associate them with a country and not a language.
+	languageCountries = map[string][]string{
+		"ar": {"SA", "EG", "AE", "MA", "DZ"},
+		"cs": {"CZ"},
+		"da": {"DK"},
+		"de": {"DE", "AT", "CH"},
+		"el": {"GR", "CY"},
+		"en": {"US", "GB", "CA", "AU", "NZ", "IE"},
+		"es": {"ES", "MX", "AR", "CO", "CL"},
+		"et": {"EE"},
+		"fi": {"FI"},
+		"fr": {"FR", "BE", "CA", "CH"},
+		"he": {"IL"},
+		"hi": {"IN"},
+		"hu": {"HU"},
+		"it": {"IT"},
+		"ja": {"JP"},
+		"ko": {"KR"},
+		"lt": {"LT"},
+		"lv": {"LV"},
+		"nb": {"NO"},
+		"nl": {"NL", "BE"},
+		"no": {"NO"},
+		"pl": {"PL"},
+		"pt": {"PT", "BR"},
+		"ro": {"RO"},
+		"ru": {"RU"},
+		"sk": {"SK"},
+		"sv": {"SE"},
+		"th": {"TH"},
+		"tr": {"TR"},
+		"uk": {"UA"},
+		"vi": {"VN"},
+		"zh": {"CN", "TW", "HK"},
+	}
+)
+
+// Language is a parsed language tag such as "de" or "pt-BR".
+type Language struct {
+	Code   string
+	Region string
+}
+
+// ParseLanguage parses a language tag in the form "language" or "language-REGION",
+// accepting either "-" or "_" as a separator. The language is lowercased and the
+// region uppercased.
+func ParseLanguage(tag string) Language {
+	tag = strings.TrimSpace(strings.ReplaceAll(tag, "_", "-"))
+	code, region, _ := strings.Cut(tag, "-")
+	return Language{Code: strings.ToLower(code), Region: strings.ToUpper(region)}
+}
+
+// IsZero returns true if no language was specified.
+func (l Language) IsZero() bool {
+	return l.Code == ""
+}
+
+// String returns the language in "language-REGION" form, or just "language" if
+// there is no region.
+func (l Language) String() string {
+	if l.Region == "" {
+		return l.Code
+	}
+
+	return l.Code + "-" + l.Region
+}
+
+// Countries returns the country codes associated with the language, most specific
+// first. If the language has a region, only the region is returned.
+func (l Language) Countries() []string {
+	if l.Region != "" {
+		return []string{l.Region}
+	}
+
+	if countries, ok := languageCountries[l.Code]; ok {
+		return countries
+	}
+
+	return []string{strings.ToUpper(l.Code)}
+}
@@ -0,0 +1,52 @@
+package mediarename
+
+import (
+	"testing"
+)
+
+func TestParseLanguage(t *testing.T) {
+	t.Run("language only", func(t *testing.T) {
+		lang := ParseLanguage("DE")
+
+		RequireEqual(t, "de", lang.Code)
+		RequireEqual(t, "", lang.Region)
+		RequireEqual(t, "de", lang.String())
+	})
+
+	t.Run("language and region", func(t *testing.T) {
+		lang := ParseLanguage("pt_br")
+
+		RequireEqual(t, "pt", lang.Code)
+		RequireEqual(t, "BR", lang.Region)
+		RequireEqual(t, "pt-BR", lang.String())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		lang := ParseLanguage("")
+
+		RequireEqual(t, true, lang.IsZero())
+	})
+}
+
+func TestLanguage_Countries(t *testing.T) {
+	t.Run("region", func(t *testing.T) {
+		countries := ParseLanguage("pt-BR").Countries()
+
+		RequireEqual(t, 1, len(countries))
+		RequireEqual(t, "BR", countries[0])
+	})
+
+	t.Run("known language", func(t *testing.T) {
+		countries := ParseLanguage("sv").Countries()
+
+		RequireEqual(t, 1, len(countries))
+		RequireEqual(t, "SE", countries[0])
+	})
+
+	t.Run("unknown language", func(t *testing.T) {
+		countries := ParseLanguage("xx").Countries()
+
+		RequireEqual(t, 1, len(countries))
+		RequireEqual(t, "XX", countries[0])
+	})
+}
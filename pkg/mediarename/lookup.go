@@ -31,6 +31,12 @@ func NewEpisodeLookup(episodes Episodes, logger *slog.Logger) *EpisodeLookup {
 	return &EpisodeLookup{lookup: lookup, logger: logger}
 }
 
+// hasEpisodeTag returns true if the name of the file at p has a season and episode
+// number that FindEpisodes would look up.
+func hasEpisodeTag(p string) bool {
+	return multiRegex.MatchString(path.Base(p))
+}
+
 func (l *EpisodeLookup) FindEpisodes(p string) (Episodes, error) {
 	file := path.Base(p)
 
@@ -0,0 +1,364 @@
+package mediarename
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultTemplate generates names like "show/season_01/show-s01e01-title".
+	DefaultTemplate = `{{sanitize .Show}}/season_{{pad 2 .Season}}/{{sanitize .Show}}-{{.Tag}}-{{sanitize .Title}}`
+
+	// pathSep stands in for "/" within metadata while a template is executed so that
+	// it isn't confused with a directory separator in the template itself.
+	pathSep = "\x00"
+)
+
+var (
+	ErrNameTooLong = errors.New("name too long")
+	ErrEmptyName   = errors.New("empty name")
+
+	defaultTemplate = MustParseNameTemplate(DefaultTemplate)
+
+	// presets are templates that follow the documented naming conventions of media servers.
+	presets = map[string]string{
+		"plex": `{{.Show}}{{if .Year}} ({{.Year}}){{end}}{{if .IDs.TheTvDb}} {tvdb-{{.IDs.TheTvDb}}}{{end}}/` +
+			`{{if eq .Season 0}}Specials{{else}}Season {{pad 2 .Season}}{{end}}/` +
+			`{{.Show}}{{if .Year}} ({{.Year}}){{end}} - {{.Tag}} - {{.Title}}`,
+		"jellyfin": `{{.Show}}{{if .Year}} ({{.Year}}){{end}}{{if .IDs.TheTvDb}} [tvdbid-{{.IDs.TheTvDb}}]{{end}}/` +
+			`Season {{pad 2 .Season}}/` +
+			`{{.Show}} {{upper .Tag}} - {{.Title}}`,
+		"emby": `{{.Show}}{{if .Year}} ({{.Year}}){{end}}{{if .IDs.TheTvDb}} [tvdbid={{.IDs.TheTvDb}}]{{end}}/` +
+			`{{if eq .Season 0}}Specials{{else}}Season {{.Season}}{{end}}/` +
+			`{{.Show}} - {{upper .Tag}} - {{.Title}}`,
+		"kodi": `{{.Show}}{{if .Year}} ({{.Year}}){{end}}/` +
+			`{{if eq .Season 0}}Specials{{else}}Season {{pad 2 .Season}}{{end}}/` +
+			`{{.Show}} S{{pad 2 .Season}}{{range .Episodes}}E{{pad 2 .}}{{end}} - {{.Title}}`,
+	}
+)
+
+// Presets returns the names of all naming presets.
+func Presets() []string {
+	return slices.Sorted(maps.Keys(presets))
+}
+
+// PresetTemplate returns the template for a naming preset that follows the conventions
+// of a media server: "plex", "jellyfin", "emby", or "kodi".
+func PresetTemplate(name string) (*NameTemplate, error) {
+	text, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown naming preset %s", name)
+	}
+
+	return ParseNameTemplate(text)
+}
+
+// NameData is the information about a file and the episodes it contains that is
+// available to a NameTemplate.
+type NameData struct {
+	// Show is the name of the show.
+	Show string
+	// Year is the year the show premiered or zero if unknown.
+	Year int
+	// Season is the season number.
+	Season int
+	// Episode is the number of the first episode in the file.
+	Episode int
+	// Episodes are the numbers of all episodes in the file.
+	Episodes []int
+	// Tag is the season and episode numbers, e.g. "s01e01" or "s01e01-e02".
+	Tag string
+	// Title is the name of the first episode in the file.
+	Title string
+	// Titles are the names of all episodes in the file.
+	Titles []string
+	// Absolute is the number of the first episode counting from the start of the
+	// show, ignoring seasons and specials, or zero if unknown.
+	Absolute int
+	// Airdate is the date the first episode aired as "YYYY-MM-DD", if known.
+	Airdate string
+	// IDs are identifiers for the show from various providers.
+	IDs struct {
+		TvMaze  int
+		TheTvDb int
+		TvRage  int
+		Imdb    string
+	}
+	// Release is information about the release parsed from the original file name.
+	Release Release
+	// Ext is the extension of the file, including the leading ".".
+	Ext string
+}
+
+// NameTemplate generates the path of a file using text/template. Templates generate a
+// path relative to the destination directory without an extension, with directories
+// separated by "/". Any "/" in show or episode names is replaced with "_" and each
+// part of the path is made valid for the destination filesystem by a Sanitizer.
+type NameTemplate struct {
+	tmpl *template.Template
+}
+
+// ParseNameTemplate parses text as a template for file names. In addition to the standard
+// text/template functions, the following functions are available:
+//
+//   - pad WIDTH N: N with leading zeros to at least WIDTH digits
+//   - upper S, lower S, title S: S in upper case, lower case, or with each word capitalized
+//   - join SEP LIST: elements of LIST separated by SEP
+//   - sanitize S: S with spaces replaced by a separator, punctuation removed, and lowercased
+func ParseNameTemplate(text string) (*NameTemplate, error) {
+	tmpl, err := template.New("name").Option("missingkey=error").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse name template: %w", err)
+	}
+
+	return &NameTemplate{tmpl: tmpl}, nil
+}
+
+// MustParseNameTemplate is like ParseNameTemplate but panics if text cannot be parsed.
+func MustParseNameTemplate(text string) *NameTemplate {
+	t, err := ParseNameTemplate(text)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+// Execute generates the path of a file under dest from data, including the extension.
+// Each part of the path is cleaned up by san to make it valid on the destination.
+func (t *NameTemplate) Execute(dest string, data NameData, san Sanitizer) (string, error) {
+	data = escapeData(data)
+
+	tmpl, err := t.tmpl.Clone()
+	if err != nil {
+		return "", fmt.Errorf("unable to clone name template: %w", err)
+	}
+
+	var sb strings.Builder
+	tmpl.Funcs(template.FuncMap{"sanitize": func(val string) (string, error) {
+		// Checked here as well since an empty value is usually only part of a segment
+		out := san.Sanitize(val)
+		if san.Segment(out) == "" && strings.TrimSpace(val) != "" {
+			return "", fmt.Errorf("%w: nothing left of %q after sanitizing", ErrEmptyName, val)
+		}
+
+		return out, nil
+	}})
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("unable to execute name template: %w", err)
+	}
+
+	var parts []string
+	for _, raw := range strings.Split(sb.String(), "/") {
+		part := san.Segment(raw)
+		if part == "" && strings.TrimSpace(raw) != "" {
+			return "", fmt.Errorf("%w: nothing left of %q after sanitizing", ErrEmptyName, raw)
+		}
+
+		if part != "" && part != "." && part != ".." {
+			parts = append(parts, part)
+		}
+	}
+
+	if len(parts) == 0 {
+		return "", fmt.Errorf("name template generated an empty name for %s", data.Tag)
+	}
+
+	parts[len(parts)-1] += data.Ext
+	return path.Join(append([]string{dest}, parts...)...), nil
+}
+
+// Limits are the maximum lengths, in bytes, of generated names. Zero means no limit.
+type Limits struct {
+	// MaxName is the maximum length of a file name, including the extension.
+	MaxName int
+	// MaxPath is the maximum length of the full, absolute, path of a file.
+	MaxPath int
+	// Reserve is the number of bytes to leave free in both the file name and path, such
+	// as for companion files with longer extensions that will share the name.
+	Reserve int
+}
+
+// DefaultLimits returns the limits of most Linux filesystems: 255 byte file names and
+// 4096 byte paths.
+func DefaultLimits() Limits {
+	return Limits{MaxName: 255, MaxPath: 4096}
+}
+
+// exceeds returns how many bytes p is over the limits, or zero if it fits. Relative paths
+// are measured from the current directory.
+func (l Limits) exceeds(p string) int {
+	over := 0
+	if l.MaxName > 0 {
+		over = max(over, len(path.Base(p))+l.Reserve-l.MaxName)
+	}
+
+	if l.MaxPath > 0 {
+		over = max(over, len(absPath(p))+l.Reserve-l.MaxPath)
+	}
+
+	return over
+}
+
+// ExecuteLimited is like Execute but shortens episode titles so that the generated
+// path fits within limits. Other parts of the name, such as the show name, episode
+// tag, and extension, are never shortened. Returns true if titles were shortened or
+// an error wrapping ErrNameTooLong if the path is too long even without titles.
+func (t *NameTemplate) ExecuteLimited(dest string, data NameData, san Sanitizer, limits Limits) (string, bool, error) {
+	name, err := t.Execute(dest, data, san)
+	if err != nil {
+		return "", false, err
+	}
+
+	over := limits.exceeds(name)
+	if over == 0 {
+		return name, false, nil
+	}
+
+	longest := len(data.Title)
+	for _, title := range data.Titles {
+		longest = max(longest, len(title))
+	}
+
+	// Titles may appear more than once or be changed by sanitizing, so shorten them and
+	// check again until the name fits or there's nothing left to shorten.
+	original := data
+	for size := longest - over; size > 0 && over > 0; size -= over {
+		data.Title = truncateBytes(original.Title, size)
+		data.Titles = make([]string, len(original.Titles))
+		for i, title := range original.Titles {
+			data.Titles[i] = truncateBytes(title, size)
+		}
+
+		name, err = t.Execute(dest, data, san)
+		if err != nil {
+			return "", false, err
+		}
+
+		over = limits.exceeds(name)
+	}
+
+	if over > 0 {
+		return "", false, fmt.Errorf("%w: %s is %d bytes over the limit", ErrNameTooLong, name, over)
+	}
+
+	return name, true, nil
+}
+
+// truncateBytes shortens s to at most n bytes without splitting a UTF-8 encoded character,
+// removing any trailing spaces or punctuation left at the end.
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return strings.TrimRightFunc(s[:n], func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+}
+
+// escapeData replaces "/" in all metadata with a placeholder so that only "/" in the
+// template itself separates directories.
+func escapeData(data NameData) NameData {
+	escape := func(s string) string { return strings.ReplaceAll(s, "/", pathSep) }
+
+	data.Show = escape(data.Show)
+	data.Title = escape(data.Title)
+	data.Titles = slices.Clone(data.Titles)
+	for i := range data.Titles {
+		data.Titles[i] = escape(data.Titles[i])
+	}
+
+	data.IDs.Imdb = escape(data.IDs.Imdb)
+	data.Release.Resolution = escape(data.Release.Resolution)
+	data.Release.Source = escape(data.Release.Source)
+	data.Release.Codec = escape(data.Release.Codec)
+	data.Release.Group = escape(data.Release.Group)
+	return data
+}
+
+var templateFuncs = template.FuncMap{
+	"pad": func(width int, n int) string {
+		return fmt.Sprintf("%0*d", width, n)
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"title": titleCase,
+	"join": func(sep string, vals any) (string, error) {
+		switch v := vals.(type) {
+		case []string:
+			return strings.Join(v, sep), nil
+		case []int:
+			s := make([]string, len(v))
+			for i, n := range v {
+				s[i] = strconv.Itoa(n)
+			}
+
+			return strings.Join(s, sep), nil
+		default:
+			return "", fmt.Errorf("unable to join %T", vals)
+		}
+	},
+	"sanitize": DefaultSanitizer().Sanitize,
+}
+
+// titleCase capitalizes the first letter of each space separated word in s.
+func titleCase(s string) string {
+	words := strings.Split(s, " ")
+	for i, w := range words {
+		if c, size := utf8.DecodeRuneInString(w); size > 0 {
+			words[i] = string(unicode.ToUpper(c)) + w[size:]
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
+// absoluteNumbers returns the absolute number of each regular episode, by episode ID,
+// counting from the first episode of the first season.
+func absoluteNumbers(episodes Episodes) map[int]int {
+	regular := slices.DeleteFunc(slices.Clone(episodes), func(e Episode) bool {
+		return e.Season < 1 || e.Number < 1 || (e.Type != "" && e.Type != "regular")
+	})
+
+	slices.SortStableFunc(regular, func(a, b Episode) int {
+		if a.Season != b.Season {
+			return a.Season - b.Season
+		}
+
+		return a.Number - b.Number
+	})
+
+	out := make(map[int]int, len(regular))
+	for i, e := range regular {
+		out[e.ID] = i + 1
+	}
+
+	return out
+}
+
+// premiereYear returns the year from a "YYYY-MM-DD" date or zero if it isn't valid.
+func premiereYear(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+
+	year, err := strconv.Atoi(date[:4])
+	if err != nil {
+		return 0
+	}
+
+	return year
+}
@@ -0,0 +1,291 @@
+package mediarename
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+)
+
+func TestNameTemplate_Execute(t *testing.T) {
+	data := NameData{
+		Show:     "The Show",
+		Year:     2019,
+		Season:   1,
+		Episode:  1,
+		Episodes: []int{1, 2},
+		Tag:      "s01e01-e02",
+		Title:    "Pilot",
+		Titles:   []string{"Pilot", "Events"},
+		Absolute: 1,
+		Airdate:  "2019-05-06",
+		Release:  Release{Resolution: "1080p", Source: "WEB-DL", Codec: "x264", Group: "GROUP"},
+		Ext:      ".mkv",
+	}
+	data.IDs.TheTvDb = 123
+
+	t.Run("default", func(t *testing.T) {
+		name, err := defaultTemplate.Execute("dest", data, DefaultSanitizer())
+
+		RequireNoError(t, err)
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e01-e02-pilot.mkv", name)
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		tmpl, err := ParseNameTemplate(`{{.Show}} ({{.Year}})/Season {{pad 2 .Season}}/{{.Show}} - {{upper .Tag}} - {{join " & " .Titles}}`)
+		RequireNoError(t, err)
+		name, err := tmpl.Execute("dest", data, DefaultSanitizer())
+
+		RequireNoError(t, err)
+		RequireEqual(t, "dest/The Show (2019)/Season 01/The Show - S01E01-E02 - Pilot & Events.mkv", name)
+	})
+
+	t.Run("ids and release", func(t *testing.T) {
+		tmpl, err := ParseNameTemplate(`{{.Show}} [tvdb-{{.IDs.TheTvDb}}]/{{pad 3 .Absolute}} {{title (lower .Title)}} {{.Airdate}} [{{.Release.Resolution}}]`)
+		RequireNoError(t, err)
+		name, err := tmpl.Execute("dest", data, DefaultSanitizer())
+
+		RequireNoError(t, err)
+		RequireEqual(t, "dest/The Show [tvdb-123]/001 Pilot 2019-05-06 [1080p].mkv", name)
+	})
+
+	t.Run("slash in metadata", func(t *testing.T) {
+		d := data
+		d.Show = "AC/DC"
+		tmpl, err := ParseNameTemplate(`{{.Show}}/{{.Title}}`)
+		RequireNoError(t, err)
+		name, err := tmpl.Execute("dest", d, DefaultSanitizer())
+
+		RequireNoError(t, err)
+		RequireEqual(t, "dest/AC_DC/Pilot.mkv", name)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		tmpl, err := ParseNameTemplate(`{{if false}}x{{end}}`)
+		RequireNoError(t, err)
+		_, err = tmpl.Execute("dest", data, DefaultSanitizer())
+
+		RequireEqual(t, true, err != nil)
+	})
+
+	t.Run("ascii", func(t *testing.T) {
+		d := data
+		d.Show = "Кухня"
+		san := DefaultSanitizer()
+		san.ASCII = true
+		name, err := defaultTemplate.Execute("dest", d, san)
+
+		RequireNoError(t, err)
+		RequireEqual(t, "dest/kukhnya/season_01/kukhnya-s01e01-e02-pilot.mkv", name)
+	})
+
+	t.Run("empty after sanitizing", func(t *testing.T) {
+		d := data
+		d.Show = "★"
+		san := DefaultSanitizer()
+		san.ASCII = true
+
+		_, err := defaultTemplate.Execute("dest", d, san)
+		RequireErrorIs(t, err, ErrEmptyName)
+
+		tmpl, err := ParseNameTemplate(`{{.Show}}/{{.Title}}`)
+		RequireNoError(t, err)
+		_, err = tmpl.Execute("dest", d, san)
+		RequireErrorIs(t, err, ErrEmptyName)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		tmpl, err := ParseNameTemplate(`{{.Nope}}`)
+		RequireNoError(t, err)
+		_, err = tmpl.Execute("dest", data, DefaultSanitizer())
+
+		RequireEqual(t, true, err != nil)
+	})
+}
+
+func TestNameTemplate_ExecuteLimited(t *testing.T) {
+	tmpl := MustParseNameTemplate(`{{.Show}}/{{.Tag}} - {{.Title}}`)
+	data := NameData{Show: "Show", Tag: "s01e01", Title: "A Very Long Title", Ext: ".mkv"}
+
+	t.Run("fits", func(t *testing.T) {
+		name, truncated, err := tmpl.ExecuteLimited("dest", data, DefaultSanitizer(), DefaultLimits())
+
+		RequireNoError(t, err)
+		RequireEqual(t, "dest/Show/s01e01 - A Very Long Title.mkv", name)
+		RequireEqual(t, false, truncated)
+	})
+
+	t.Run("name too long", func(t *testing.T) {
+		name, truncated, err := tmpl.ExecuteLimited("dest", data, DefaultSanitizer(), Limits{MaxName: 20})
+
+		RequireNoError(t, err)
+		RequireEqual(t, "dest/Show/s01e01 - A Very.mkv", name)
+		RequireEqual(t, true, truncated)
+	})
+
+	t.Run("path too long", func(t *testing.T) {
+		name, truncated, err := tmpl.ExecuteLimited("/dest", data, DefaultSanitizer(), Limits{MaxPath: 31})
+
+		RequireNoError(t, err)
+		RequireEqual(t, "/dest/Show/s01e01 - A Very.mkv", name)
+		RequireEqual(t, true, truncated)
+	})
+
+	t.Run("relative path too long", func(t *testing.T) {
+		wd, err := os.Getwd()
+		RequireNoError(t, err)
+		name, truncated, err := tmpl.ExecuteLimited("dest", data, DefaultSanitizer(), Limits{MaxPath: len(wd) + 31})
+
+		RequireNoError(t, err)
+		RequireEqual(t, "dest/Show/s01e01 - A Very.mkv", name)
+		RequireEqual(t, true, truncated)
+	})
+
+	t.Run("reserved for companions", func(t *testing.T) {
+		name, truncated, err := tmpl.ExecuteLimited("dest", data, DefaultSanitizer(), Limits{MaxName: 30, Reserve: 10})
+
+		RequireNoError(t, err)
+		RequireEqual(t, "dest/Show/s01e01 - A Very.mkv", name)
+		RequireEqual(t, true, truncated)
+	})
+
+	t.Run("multibyte", func(t *testing.T) {
+		data := data
+		data.Title = "Caf\u00e9 Caf\u00e9"
+		name, truncated, err := tmpl.ExecuteLimited("dest", data, DefaultSanitizer(), Limits{MaxName: 17})
+
+		RequireNoError(t, err)
+		RequireEqual(t, "dest/Show/s01e01 - Caf.mkv", name)
+		RequireEqual(t, true, truncated)
+	})
+
+	t.Run("too long without title", func(t *testing.T) {
+		_, _, err := tmpl.ExecuteLimited("dest", data, DefaultSanitizer(), Limits{MaxName: 10})
+
+		RequireErrorIs(t, err, ErrNameTooLong)
+	})
+}
+
+func TestParseRelease(t *testing.T) {
+	t.Run("full", func(t *testing.T) {
+		r := ParseRelease("src/Show.S01E01.1080p.WEB-DL.x264-GROUP.mkv")
+
+		RequireEqual(t, Release{Resolution: "1080p", Source: "WEB-DL", Codec: "x264", Group: "GROUP"}, r)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		r := ParseRelease("src/show-s01e01-some-title.mkv")
+
+		RequireEqual(t, Release{}, r)
+	})
+}
+
+func TestTvRenamer_GenerateNamesTemplate(t *testing.T) {
+	show := testShow
+	show.Premiered = "2019-05-06"
+	tmpl := MustParseNameTemplate(`{{.Show}} ({{.Year}})/Season {{pad 2 .Season}}/{{.Show}} - {{upper .Tag}} - {{.Title}}`)
+	client := &fakeClient{show: show, episodes: testEpisodes}
+	renamer := NewTvRenamer(client, TvOptions{Template: tmpl}, slog.New(slog.DiscardHandler))
+	renames, err := renamer.GenerateNames(mediaFiles("src/Show.S01E123.mkv"), "dest", "tt1234")
+
+	RequireNoError(t, err)
+	RequireEqual(t, 1, len(renames))
+	RequireEqual(t, "dest/The Show (2019)/Season 01/The Show - S01E123 - Finale.mkv", renames[0].New)
+}
+
+func TestTvRenamer_GenerateNamesTemplateError(t *testing.T) {
+	tmpl := MustParseNameTemplate(`{{.Show}}/{{if eq .Tag "s01e02"}}{{.Missing}}{{end}}{{.Tag}}`)
+	client := &fakeClient{show: testShow, episodes: testEpisodes}
+	renamer := NewTvRenamer(client, TvOptions{Template: tmpl}, slog.New(slog.DiscardHandler))
+	renames, err := renamer.GenerateNames(mediaFiles("src/Show.S01E01.mkv", "src/Show.S01E02.mkv"), "dest", "tt1234")
+
+	RequireNoError(t, err)
+	RequireEqual(t, 1, len(renames))
+	RequireEqual(t, "dest/The Show/s01e01.mkv", renames[0].New)
+}
+
+func TestAbsoluteNumbers(t *testing.T) {
+	episodes := Episodes{
+		{ID: 1, Season: 2, Number: 1, Type: "regular"},
+		{ID: 2, Season: 1, Number: 2, Type: "regular"},
+		{ID: 3, Season: 1, Number: 1, Type: "regular"},
+		{ID: 4, Season: 0, Number: 1, Type: "regular"},
+		{ID: 5, Season: 1, Number: 3, Type: "significant_special"},
+	}
+
+	absolute := absoluteNumbers(episodes)
+
+	RequireEqual(t, 3, len(absolute))
+	RequireEqual(t, 1, absolute[3])
+	RequireEqual(t, 2, absolute[2])
+	RequireEqual(t, 3, absolute[1])
+}
+
+func TestPresetTemplate(t *testing.T) {
+	single := NameData{
+		Show:     "The Show",
+		Year:     2019,
+		Season:   1,
+		Episode:  1,
+		Episodes: []int{1},
+		Tag:      "s01e01",
+		Title:    "Pilot",
+		Titles:   []string{"Pilot"},
+		Ext:      ".mkv",
+	}
+	single.IDs.TheTvDb = 123
+
+	multi := single
+	multi.Episodes = []int{1, 2}
+	multi.Tag = "s01e01-e02"
+	multi.Titles = []string{"Pilot", "Events"}
+
+	special := single
+	special.Season = 0
+	special.Tag = "s00e01"
+	special.Title = "Christmas Special"
+
+	unknown := single
+	unknown.Year = 0
+	unknown.IDs.TheTvDb = 0
+
+	cases := []struct {
+		name     string
+		preset   string
+		data     NameData
+		expected string
+	}{
+		{"plex single", "plex", single, "dest/The Show (2019) {tvdb-123}/Season 01/The Show (2019) - s01e01 - Pilot.mkv"},
+		{"plex multi", "plex", multi, "dest/The Show (2019) {tvdb-123}/Season 01/The Show (2019) - s01e01-e02 - Pilot.mkv"},
+		{"plex special", "plex", special, "dest/The Show (2019) {tvdb-123}/Specials/The Show (2019) - s00e01 - Christmas Special.mkv"},
+		{"plex unknown", "plex", unknown, "dest/The Show/Season 01/The Show - s01e01 - Pilot.mkv"},
+		{"jellyfin single", "jellyfin", single, "dest/The Show (2019) [tvdbid-123]/Season 01/The Show S01E01 - Pilot.mkv"},
+		{"jellyfin multi", "jellyfin", multi, "dest/The Show (2019) [tvdbid-123]/Season 01/The Show S01E01-E02 - Pilot.mkv"},
+		{"jellyfin special", "jellyfin", special, "dest/The Show (2019) [tvdbid-123]/Season 00/The Show S00E01 - Christmas Special.mkv"},
+		{"jellyfin unknown", "jellyfin", unknown, "dest/The Show/Season 01/The Show S01E01 - Pilot.mkv"},
+		{"emby single", "emby", single, "dest/The Show (2019) [tvdbid=123]/Season 1/The Show - S01E01 - Pilot.mkv"},
+		{"emby multi", "emby", multi, "dest/The Show (2019) [tvdbid=123]/Season 1/The Show - S01E01-E02 - Pilot.mkv"},
+		{"emby special", "emby", special, "dest/The Show (2019) [tvdbid=123]/Specials/The Show - S00E01 - Christmas Special.mkv"},
+		{"emby unknown", "emby", unknown, "dest/The Show/Season 1/The Show - S01E01 - Pilot.mkv"},
+		{"kodi single", "kodi", single, "dest/The Show (2019)/Season 01/The Show S01E01 - Pilot.mkv"},
+		{"kodi multi", "kodi", multi, "dest/The Show (2019)/Season 01/The Show S01E01E02 - Pilot.mkv"},
+		{"kodi special", "kodi", special, "dest/The Show (2019)/Specials/The Show S00E01 - Christmas Special.mkv"},
+		{"kodi unknown", "kodi", unknown, "dest/The Show/Season 01/The Show S01E01 - Pilot.mkv"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tmpl, err := PresetTemplate(c.preset)
+			RequireNoError(t, err)
+			name, err := tmpl.Execute("dest", c.data, DefaultSanitizer())
+
+			RequireNoError(t, err)
+			RequireEqual(t, c.expected, name)
+		})
+	}
+
+	t.Run("unknown preset", func(t *testing.T) {
+		_, err := PresetTemplate("nope")
+
+		RequireEqual(t, true, err != nil)
+	})
+}
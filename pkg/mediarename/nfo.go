@@ -0,0 +1,314 @@
+package mediarename
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// tagRegex matches HTML tags in summaries from metadata providers.
+	tagRegex = regexp.MustCompile(`<[^>]*>`)
+)
+
+// nfoUniqueID is an identifier for a show or episode from a metadata provider.
+type nfoUniqueID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr,omitempty"`
+	Value   string `xml:",chardata"`
+}
+
+// nfoActor is a member of the cast of a show.
+type nfoActor struct {
+	Name  string `xml:"name"`
+	Role  string `xml:"role,omitempty"`
+	Order int    `xml:"order"`
+}
+
+// nfoEpisode is the metadata for an episode read by Kodi, Jellyfin, and Emby.
+type nfoEpisode struct {
+	XMLName   xml.Name      `xml:"episodedetails"`
+	Title     string        `xml:"title"`
+	ShowTitle string        `xml:"showtitle"`
+	Season    int           `xml:"season"`
+	Episode   int           `xml:"episode"`
+	Plot      string        `xml:"plot,omitempty"`
+	Aired     string        `xml:"aired,omitempty"`
+	Runtime   int           `xml:"runtime,omitempty"`
+	UniqueIDs []nfoUniqueID `xml:"uniqueid"`
+	Actors    []nfoActor    `xml:"actor"`
+}
+
+// nfoShow is the metadata for a show read by Kodi, Jellyfin, and Emby.
+type nfoShow struct {
+	XMLName   xml.Name      `xml:"tvshow"`
+	Title     string        `xml:"title"`
+	Plot      string        `xml:"plot,omitempty"`
+	Premiered string        `xml:"premiered,omitempty"`
+	Year      int           `xml:"year,omitempty"`
+	Runtime   int           `xml:"runtime,omitempty"`
+	Genres    []string      `xml:"genre"`
+	UniqueIDs []nfoUniqueID `xml:"uniqueid"`
+	Actors    []nfoActor    `xml:"actor"`
+}
+
+// nfoSeason is the metadata for a season read by Kodi, Jellyfin, and Emby.
+type nfoSeason struct {
+	XMLName      xml.Name `xml:"season"`
+	Title        string   `xml:"title"`
+	SeasonNumber int      `xml:"seasonnumber"`
+}
+
+// WriteNFO writes metadata files for media servers for each renamed file: an
+// "episodedetails" NFO next to each file, a "tvshow.nfo" in each show directory, and a
+// "season.nfo" in each season directory. The show directory is the first directory
+// under dest that files were renamed into and the season directory is the directory
+// of each file, when it's below the show directory. Episode NFOs aren't written for
+// files that already have an NFO companion. Existing NFOs are only replaced if the
+// conflict policy is to overwrite. Files are only logged, not written or looked up,
+// unless committing.
+func (r *TvRenamer) WriteNFO(renames []Rename, dest string) error {
+	var actors []nfoActor
+	written := make(map[string]struct{})
+
+	for _, op := range renames {
+		if op.Skip || op.Show == nil || len(op.Episodes) == 0 {
+			continue
+		}
+
+		// Every file is from the same show so the cast only needs to be looked up once
+		cast := func() []nfoActor {
+			if actors == nil {
+				actors = nfoActors(r.cast(op.Show))
+			}
+
+			return actors
+		}
+
+		// Subtitles without a video describe an episode whose video is elsewhere
+		if !hasNFOCompanion(op) && !isCompanion(op.Old) {
+			err := r.writeNFO(trimExt(op.New)+".nfo", func() []any {
+				// Files with multiple episodes have an element for each of them
+				episodes := make([]any, 0, len(op.Episodes))
+				for _, e := range op.Episodes {
+					episodes = append(episodes, episodeNFO(op.Show, e, r.episodeIDs(op.Show, e), cast()))
+				}
+
+				return episodes
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		showDir, depth := showDir(dest, op.New)
+		if depth == 0 {
+			continue
+		}
+
+		showFile := path.Join(showDir, "tvshow.nfo")
+		if _, ok := written[showFile]; !ok {
+			written[showFile] = struct{}{}
+
+			if err := r.writeNFO(showFile, func() []any { return []any{showNFO(op.Show, cast())} }); err != nil {
+				return err
+			}
+		}
+
+		if depth < 2 {
+			continue
+		}
+
+		seasonFile := path.Join(path.Dir(op.New), "season.nfo")
+		if _, ok := written[seasonFile]; !ok {
+			written[seasonFile] = struct{}{}
+
+			if err := r.writeNFO(seasonFile, func() []any { return []any{seasonNFO(op.Episodes[0].Season)} }); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// showDir returns the directory of a show for a file renamed to p: the first directory
+// under dest. Also returns how many directories deep the file is under dest, zero if it
+// isn't in a directory under dest.
+func showDir(dest string, p string) (string, int) {
+	rel, err := filepath.Rel(dest, path.Dir(p))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", 0
+	}
+
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	return path.Join(dest, parts[0]), len(parts)
+}
+
+// cast returns the cast of a show if the client supports it, logging any errors.
+func (r *TvRenamer) cast(show *Show) []CastMember {
+	lister, ok := r.client.(CastLister)
+	if !ok {
+		return nil
+	}
+
+	cast, err := lister.Cast(show)
+	if err != nil {
+		r.logger.Warn("unable to lookup cast", "show", show.Name, "err", err)
+		return nil
+	}
+
+	return cast
+}
+
+// episodeIDs returns the identifiers of an episode from other providers if the configured
+// finder or the client supports them, logging any errors.
+func (r *TvRenamer) episodeIDs(show *Show, e Episode) EpisodeIDs {
+	finder := r.opts.EpisodeIDs
+	if finder == nil {
+		finder, _ = r.client.(EpisodeIDFinder)
+	}
+
+	if finder == nil {
+		return EpisodeIDs{}
+	}
+
+	ids, err := finder.EpisodeIDs(show, e)
+	if err != nil {
+		r.logger.Warn("unable to lookup episode IDs", "show", show.Name, "season", e.Season, "episode", e.Number, "err", err)
+		return EpisodeIDs{}
+	}
+
+	return ids
+}
+
+// writeNFO atomically writes the elements returned by build as an NFO file to p, or only
+// logs it when not committing. An existing file at p is left alone unless the conflict
+// policy is to overwrite. build is only called when the file is written since it may
+// look up metadata.
+func (r *TvRenamer) writeNFO(p string, build func() []any) error {
+	if _, err := os.Lstat(p); err == nil && r.opts.OnConflict != ConflictOverwrite {
+		r.logger.Warn("nfo already exists", "path", p, "policy", r.opts.OnConflict)
+		return nil
+	}
+
+	r.logger.Info("write nfo", "path", p)
+	if !r.opts.Commit {
+		return nil
+	}
+
+	contents, err := encodeNFO(build()...)
+	if err != nil {
+		return fmt.Errorf("unable to encode %s: %w", p, err)
+	}
+
+	return r.writeFile(p, contents)
+}
+
+// hasNFOCompanion returns true if a file is being renamed along with an existing NFO.
+func hasNFOCompanion(op Rename) bool {
+	for _, c := range op.Companions {
+		if strings.EqualFold(path.Ext(c.New), ".nfo") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// encodeNFO returns elements as indented XML following a single XML declaration.
+func encodeNFO(elements ...any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	for _, e := range elements {
+		if err := enc.Encode(e); err != nil {
+			return nil, err
+		}
+
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes(), nil
+}
+
+func episodeNFO(show *Show, e Episode, ids EpisodeIDs, actors []nfoActor) nfoEpisode {
+	uniqueIDs := []nfoUniqueID{{Type: "tvmaze", Default: true, Value: strconv.Itoa(e.ID)}}
+	if ids.Imdb != "" {
+		uniqueIDs = append(uniqueIDs, nfoUniqueID{Type: "imdb", Value: ids.Imdb})
+	}
+
+	if ids.TheTvDb != 0 {
+		uniqueIDs = append(uniqueIDs, nfoUniqueID{Type: "tvdb", Value: strconv.Itoa(ids.TheTvDb)})
+	}
+
+	if ids.Tmdb != 0 {
+		uniqueIDs = append(uniqueIDs, nfoUniqueID{Type: "tmdb", Value: strconv.Itoa(ids.Tmdb)})
+	}
+
+	return nfoEpisode{
+		Title:     e.Name,
+		ShowTitle: show.Name,
+		Season:    e.Season,
+		Episode:   e.Number,
+		Plot:      plainText(e.Summary),
+		Aired:     e.Airdate,
+		Runtime:   e.Runtime,
+		UniqueIDs: uniqueIDs,
+		Actors:    actors,
+	}
+}
+
+func showNFO(show *Show, actors []nfoActor) nfoShow {
+	ids := []nfoUniqueID{{Type: "tvmaze", Default: true, Value: strconv.Itoa(show.ID)}}
+	if show.Externals.Imdb != "" {
+		ids = append(ids, nfoUniqueID{Type: "imdb", Value: show.Externals.Imdb})
+	}
+
+	if show.Externals.TheTvDb != 0 {
+		ids = append(ids, nfoUniqueID{Type: "tvdb", Value: strconv.Itoa(show.Externals.TheTvDb)})
+	}
+
+	return nfoShow{
+		Title:     show.Name,
+		Plot:      plainText(show.Summary),
+		Premiered: show.Premiered,
+		Year:      premiereYear(show.Premiered),
+		Runtime:   show.Runtime,
+		Genres:    show.Genres,
+		UniqueIDs: ids,
+		Actors:    actors,
+	}
+}
+
+func seasonNFO(season int) nfoSeason {
+	title := fmt.Sprintf("Season %d", season)
+	if season == 0 {
+		title = "Specials"
+	}
+
+	return nfoSeason{Title: title, SeasonNumber: season}
+}
+
+func nfoActors(cast []CastMember) []nfoActor {
+	actors := make([]nfoActor, 0, len(cast))
+	for i, c := range cast {
+		actors = append(actors, nfoActor{Name: c.Person.Name, Role: c.Character.Name, Order: i})
+	}
+
+	return actors
+}
+
+// plainText converts an HTML summary from a metadata provider to plain text.
+func plainText(s string) string {
+	return strings.TrimSpace(html.UnescapeString(tagRegex.ReplaceAllString(s, "")))
+}
@@ -0,0 +1,157 @@
+package mediarename
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type fakeCastClient struct {
+	fakeClient
+	cast []CastMember
+}
+
+func (c *fakeCastClient) Cast(*Show) ([]CastMember, error) {
+	return c.cast, nil
+}
+
+type fakeEpisodeIDFinder struct {
+	ids   map[int]EpisodeIDs
+	calls int
+}
+
+func (f *fakeEpisodeIDFinder) EpisodeIDs(_ *Show, e Episode) (EpisodeIDs, error) {
+	f.calls++
+	return f.ids[e.ID], nil
+}
+
+func TestTvRenamer_WriteNFO(t *testing.T) {
+	show := testShow
+	show.Premiered = "2019-05-06"
+	show.Summary = "<p>A show about <b>things</b> &amp; stuff.</p>"
+	show.Externals.Imdb = "tt1234"
+	show.Externals.TheTvDb = 123
+
+	episode := testEpisodes[0]
+	episode.Airdate = "2019-05-06"
+	episode.Summary = "<p>It begins.</p>"
+
+	var member CastMember
+	member.Person.Name = "Some Actor"
+	member.Character.Name = "Some Character"
+	client := &fakeCastClient{cast: []CastMember{member}}
+
+	setup := func(t *testing.T) (string, []Rename) {
+		dest := filepath.Join(t.TempDir(), "dest")
+		return dest, []Rename{{
+			Old:      "src/Show.S01E01.mkv",
+			New:      filepath.Join(dest, "the_show", "season_01", "the_show-s01e01-pilot.mkv"),
+			Show:     &show,
+			Episodes: Episodes{episode},
+		}}
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		dest, renames := setup(t)
+		renamer := NewTvRenamer(client, TvOptions{Commit: true}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.WriteNFO(renames, dest))
+
+		episodeNFO := readTestFile(t, filepath.Join(dest, "the_show", "season_01", "the_show-s01e01-pilot.nfo"))
+		for _, s := range []string{
+			"<episodedetails>",
+			"<title>Pilot</title>",
+			"<showtitle>The Show</showtitle>",
+			"<plot>It begins.</plot>",
+			"<aired>2019-05-06</aired>",
+			`<uniqueid type="tvmaze" default="true">1</uniqueid>`,
+			"<name>Some Actor</name>",
+		} {
+			RequireEqual(t, true, strings.Contains(episodeNFO, s))
+		}
+
+		showNFO := readTestFile(t, filepath.Join(dest, "the_show", "tvshow.nfo"))
+		for _, s := range []string{
+			"<tvshow>",
+			"<plot>A show about things &amp; stuff.</plot>",
+			"<year>2019</year>",
+			`<uniqueid type="imdb">tt1234</uniqueid>`,
+			`<uniqueid type="tvdb">123</uniqueid>`,
+			"<role>Some Character</role>",
+		} {
+			RequireEqual(t, true, strings.Contains(showNFO, s))
+		}
+
+		seasonNFO := readTestFile(t, filepath.Join(dest, "the_show", "season_01", "season.nfo"))
+		RequireEqual(t, true, strings.Contains(seasonNFO, "<seasonnumber>1</seasonnumber>"))
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		dest, renames := setup(t)
+		finder := &fakeEpisodeIDFinder{}
+		renamer := NewTvRenamer(client, TvOptions{EpisodeIDs: finder}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.WriteNFO(renames, dest))
+
+		_, err := os.Stat(filepath.Join(dest, "the_show", "tvshow.nfo"))
+		RequireEqual(t, true, errors.Is(err, os.ErrNotExist))
+		RequireEqual(t, 0, finder.calls)
+	})
+
+	t.Run("existing nfo", func(t *testing.T) {
+		dest, renames := setup(t)
+		showFile := filepath.Join(dest, "the_show", "tvshow.nfo")
+		writeTestFile(t, showFile, "original")
+
+		renamer := NewTvRenamer(client, TvOptions{Commit: true}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.WriteNFO(renames, dest))
+		RequireEqual(t, "original", readTestFile(t, showFile))
+
+		renamer = NewTvRenamer(client, TvOptions{Commit: true, OnConflict: ConflictOverwrite}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.WriteNFO(renames, dest))
+		RequireEqual(t, true, strings.Contains(readTestFile(t, showFile), "<tvshow>"))
+	})
+
+	t.Run("existing nfo companion", func(t *testing.T) {
+		dest, renames := setup(t)
+		companion := filepath.Join(dest, "the_show", "season_01", "the_show-s01e01-pilot.nfo")
+		writeTestFile(t, companion, "original")
+		renames[0].Companions = []Rename{{Old: "src/Show.S01E01.nfo", New: companion}}
+
+		renamer := NewTvRenamer(client, TvOptions{Commit: true}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.WriteNFO(renames, dest))
+		RequireEqual(t, "original", readTestFile(t, companion))
+	})
+
+	t.Run("episode IDs", func(t *testing.T) {
+		dest, renames := setup(t)
+		finder := &fakeEpisodeIDFinder{ids: map[int]EpisodeIDs{1: {Imdb: "tt5678", TheTvDb: 456, Tmdb: 789}}}
+		renamer := NewTvRenamer(client, TvOptions{Commit: true, EpisodeIDs: finder}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.WriteNFO(renames, dest))
+
+		episodeNFO := readTestFile(t, filepath.Join(dest, "the_show", "season_01", "the_show-s01e01-pilot.nfo"))
+		for _, s := range []string{
+			`<uniqueid type="tvmaze" default="true">1</uniqueid>`,
+			`<uniqueid type="imdb">tt5678</uniqueid>`,
+			`<uniqueid type="tvdb">456</uniqueid>`,
+			`<uniqueid type="tmdb">789</uniqueid>`,
+		} {
+			RequireEqual(t, true, strings.Contains(episodeNFO, s))
+		}
+	})
+
+	t.Run("loose subtitle", func(t *testing.T) {
+		dest, renames := setup(t)
+		renames[0].Old = "src/Show.S01E01.en.srt"
+		renames[0].New = filepath.Join(dest, "the_show", "season_01", "the_show-s01e01-pilot.en.srt")
+
+		renamer := NewTvRenamer(client, TvOptions{Commit: true}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.WriteNFO(renames, dest))
+
+		_, err := os.Stat(filepath.Join(dest, "the_show", "season_01", "the_show-s01e01-pilot.en.nfo"))
+		RequireEqual(t, true, errors.Is(err, os.ErrNotExist))
+		_, err = os.Stat(filepath.Join(dest, "the_show", "tvshow.nfo"))
+		RequireNoError(t, err)
+	})
+}
@@ -0,0 +1,124 @@
+package mediarename
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	// transliterations are ASCII replacements for letters and punctuation that
+	// don't decompose into an ASCII letter and combining marks.
+	transliterations = map[rune]string{
+		'ß': "ss", 'ẞ': "SS",
+		'æ': "ae", 'Æ': "AE",
+		'œ': "oe", 'Œ': "OE",
+		'ø': "o", 'Ø': "O",
+		'đ': "d", 'Đ': "D",
+		'ð': "d", 'Ð': "D",
+		'ł': "l", 'Ł': "L",
+		'þ': "th", 'Þ': "Th",
+		'ı': "i", 'ħ': "h", 'Ħ': "H",
+		'‘': "'", '’': "'", '‚': "'", '′': "'",
+		'“': "\"", '”': "\"", '„': "\"", '″': "\"",
+		'–': "-", '—': "-", '‐': "-", '−': "-",
+		'…': "...", '·': ".",
+		'\u00a0': " ",
+	}
+
+	// romanizations are ASCII spellings of lowercase Cyrillic and Greek letters, loosely
+	// following BGN/PCGN. Uppercase letters use the same spelling, capitalized. Letters
+	// with marks such as й or ά are romanized as their base letter.
+	romanizations = map[rune]string{
+		'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ж': "zh",
+		'з': "z", 'и': "i", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+		'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh",
+		'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "",
+		'э': "e", 'ю': "yu", 'я': "ya", 'і': "i", 'є': "ye", 'ґ': "g", 'ј': "j",
+		'љ': "lj", 'њ': "nj", 'џ': "dz", 'ђ': "dj", 'ћ': "c", 'ѕ': "dz",
+		'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+		'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+		'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+		'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	}
+)
+
+// normalizeNFC returns s in Unicode normalization form C.
+func normalizeNFC(s string) string {
+	if isASCII(s) {
+		return s
+	}
+
+	return norm.NFC.String(s)
+}
+
+// transliterateASCII returns s with letters replaced by their closest ASCII equivalents
+// by removing accents and other marks, and romanizing Cyrillic and Greek. Letters and
+// digits of other scripts, which have no ASCII equivalent, are kept as they are so that
+// names in those scripts aren't lost. Other characters without an equivalent are removed.
+func transliterateASCII(s string) string {
+	if isASCII(s) {
+		return s
+	}
+
+	var sb strings.Builder
+	for _, r := range norm.NFC.String(s) {
+		if ascii, ok := asciiRune(r); ok {
+			sb.WriteString(ascii)
+		} else if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String()
+}
+
+// asciiRune returns the ASCII equivalent of r, ignoring any marks it decomposes into.
+// Returns false if r or the letter it decomposes into has no equivalent.
+func asciiRune(r rune) (string, bool) {
+	switch {
+	case r < utf8.RuneSelf:
+		return string(r), true
+	case transliterations[r] != "":
+		return transliterations[r], true
+	case unicode.IsSpace(r):
+		return " ", true
+	}
+
+	if roman, ok := romanizations[unicode.ToLower(r)]; ok {
+		if unicode.IsUpper(r) && roman != "" {
+			roman = strings.ToUpper(roman[:1]) + roman[1:]
+		}
+
+		return roman, true
+	}
+
+	var sb strings.Builder
+	decomposed := norm.NFD.String(string(r))
+	if decomposed == string(r) {
+		return "", unicode.IsMark(r)
+	}
+
+	for _, d := range decomposed {
+		ascii, ok := asciiRune(d)
+		if !ok {
+			return "", false
+		}
+
+		sb.WriteString(ascii)
+	}
+
+	return sb.String(), true
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
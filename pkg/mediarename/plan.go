@@ -0,0 +1,463 @@
+package mediarename
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPlanChanged = errors.New("source changed since planning")
+)
+
+// planVersion is the version of the plan format written by WritePlan.
+const planVersion = 1
+
+// PlanFormat is a format that a plan can be written in.
+type PlanFormat string
+
+const (
+	// PlanJSON is a JSON document that can be edited and then run with the apply command.
+	PlanJSON PlanFormat = "json"
+	// PlanCSV is a CSV file with a row for each file, for reviewing in a spreadsheet.
+	PlanCSV PlanFormat = "csv"
+	// PlanShell is a POSIX shell script that performs the renames.
+	PlanShell PlanFormat = "shell"
+)
+
+// PlanFormats returns the names of all supported plan formats.
+func PlanFormats() []string {
+	return []string{string(PlanJSON), string(PlanCSV), string(PlanShell)}
+}
+
+// Plan is every rename that a run would make, along with how files are placed at their
+// new names, so that renames can be reviewed and edited before they are applied.
+type Plan struct {
+	Version       int         `json:"version"`
+	Created       time.Time   `json:"created"`
+	Mode          Mode        `json:"mode"`
+	RelativeLinks bool        `json:"relative_links,omitempty"`
+	Entries       []PlanEntry `json:"entries"`
+}
+
+// PlanEntry is a single file in a plan. The size and modification time of the file
+// when the plan was made are used to detect files that changed before it is applied.
+// Planned is the new name of a file with companions when the plan was made, so that
+// companions that weren't edited can follow an edited new name.
+type PlanEntry struct {
+	Old        string      `json:"old"`
+	New        string      `json:"new"`
+	Planned    string      `json:"planned,omitempty"`
+	Size       int64       `json:"size"`
+	ModTime    time.Time   `json:"mtime"`
+	Skip       bool        `json:"skip,omitempty"`
+	Overwrite  bool        `json:"overwrite,omitempty"`
+	Conflict   *Conflict   `json:"conflict,omitempty"`
+	Match      *PlanMatch  `json:"match,omitempty"`
+	Companions []PlanEntry `json:"companions,omitempty"`
+}
+
+// PlanMatch is the show and episodes, or kind of extra, a file was matched to.
+type PlanMatch struct {
+	ShowID   int           `json:"show_id"`
+	Show     string        `json:"show"`
+	Episodes []PlanEpisode `json:"episodes,omitempty"`
+	Extra    ExtraKind     `json:"extra,omitempty"`
+}
+
+// PlanEpisode is an episode a file was matched to.
+type PlanEpisode struct {
+	ID     int    `json:"id"`
+	Season int    `json:"season"`
+	Number int    `json:"number"`
+	Name   string `json:"name"`
+}
+
+// NewPlan returns a plan for renames that places files using the configured mode. The
+// size and modification time of each file to be renamed is recorded.
+func (r *TvRenamer) NewPlan(renames []Rename) (Plan, error) {
+	mode := r.opts.Mode
+	if mode == "" {
+		mode = ModeMove
+	}
+
+	plan := Plan{
+		Version:       planVersion,
+		Created:       time.Now().UTC(),
+		Mode:          mode,
+		RelativeLinks: r.opts.RelativeLinks,
+	}
+
+	for _, op := range renames {
+		entry, err := planEntry(op)
+		if err != nil {
+			return Plan{}, err
+		}
+
+		entry.Match = planMatch(op)
+		if len(op.Companions) > 0 {
+			entry.Planned = op.New
+		}
+
+		for _, c := range op.Companions {
+			companion, err := planEntry(c)
+			if err != nil {
+				return Plan{}, err
+			}
+
+			entry.Companions = append(entry.Companions, companion)
+		}
+
+		plan.Entries = append(plan.Entries, entry)
+	}
+
+	return plan, nil
+}
+
+// planEntry returns an entry for op, without companions or match metadata.
+func planEntry(op Rename) (PlanEntry, error) {
+	info, err := os.Stat(op.Old)
+	if err != nil {
+		return PlanEntry{}, fmt.Errorf("unable to stat %s for plan: %w", op.Old, err)
+	}
+
+	return PlanEntry{
+		Old:       op.Old,
+		New:       op.New,
+		Size:      info.Size(),
+		ModTime:   info.ModTime().UTC(),
+		Skip:      op.Skip,
+		Overwrite: op.Overwrite,
+		Conflict:  op.Conflict,
+	}, nil
+}
+
+// planMatch returns the show and episodes op was matched to or nil if unknown.
+func planMatch(op Rename) *PlanMatch {
+	if op.Show == nil {
+		return nil
+	}
+
+	match := PlanMatch{ShowID: op.Show.ID, Show: op.Show.Name, Extra: op.Extra}
+	for _, e := range op.Episodes {
+		match.Episodes = append(match.Episodes, PlanEpisode{ID: e.ID, Season: e.Season, Number: e.Number, Name: e.Name})
+	}
+
+	return &match
+}
+
+// WritePlan writes plan to w in the given format. Only the JSON format can be read
+// back with ReadPlan.
+func WritePlan(w io.Writer, plan Plan, format PlanFormat) error {
+	switch format {
+	case PlanJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(plan); err != nil {
+			return fmt.Errorf("unable to write plan: %w", err)
+		}
+
+		return nil
+	case PlanCSV:
+		return writePlanCSV(w, plan)
+	case PlanShell:
+		return writePlanShell(w, plan)
+	default:
+		return fmt.Errorf("unknown plan format %s", format)
+	}
+}
+
+// writePlanCSV writes a row for every file in plan, including companion files.
+func writePlanCSV(w io.Writer, plan Plan) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"old", "new", "size", "mtime", "skip", "overwrite", "conflict", "companion_of", "show_id", "show", "episodes", "titles"})
+
+	row := func(e PlanEntry, primary PlanEntry) []string {
+		var conflict, companionOf, showID, show, episodes, titles string
+		if e.Conflict != nil {
+			conflict = fmt.Sprintf("%s: %s", e.Conflict.Kind, e.Conflict.With)
+		}
+
+		if e.Old != primary.Old {
+			companionOf = primary.Old
+		}
+
+		if m := primary.Match; m != nil {
+			showID = strconv.Itoa(m.ShowID)
+			show = m.Show
+
+			var numbers, names []string
+			for _, ep := range m.Episodes {
+				numbers = append(numbers, fmt.Sprintf("s%02de%02d", ep.Season, ep.Number))
+				names = append(names, ep.Name)
+			}
+
+			episodes = strings.Join(numbers, " ")
+			titles = strings.Join(names, " / ")
+			if m.Extra != "" {
+				episodes = string(m.Extra)
+			}
+		}
+
+		return []string{
+			e.Old,
+			e.New,
+			strconv.FormatInt(e.Size, 10),
+			e.ModTime.Format(time.RFC3339Nano),
+			strconv.FormatBool(e.Skip),
+			strconv.FormatBool(e.Overwrite),
+			conflict,
+			companionOf,
+			showID,
+			show,
+			episodes,
+			titles,
+		}
+	}
+
+	for _, e := range plan.Entries {
+		_ = cw.Write(row(e, e))
+		for _, c := range e.Companions {
+			_ = cw.Write(row(c, e))
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("unable to write plan: %w", err)
+	}
+
+	return nil
+}
+
+// writePlanShell writes a shell script that creates directories and places each file
+// in plan using the commands equivalent to the plan mode. Skipped files are included
+// as comments. Existing files are only replaced when the plan overwrites them.
+func writePlanShell(w io.Writer, plan Plan) error {
+	var sb strings.Builder
+	sb.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&sb, "# mediarename plan created %s, mode %s\n", plan.Created.Format(time.RFC3339), plan.Mode)
+	sb.WriteString("set -eu\n")
+
+	for _, e := range plan.Entries {
+		sb.WriteString("\n")
+		if m := e.Match; m != nil {
+			for _, ep := range m.Episodes {
+				fmt.Fprintf(&sb, "# %s s%02de%02d %s\n", m.Show, ep.Season, ep.Number, ep.Name)
+			}
+
+			if m.Extra != "" {
+				fmt.Fprintf(&sb, "# %s %s\n", m.Show, m.Extra)
+			}
+		}
+
+		if e.Conflict != nil {
+			fmt.Fprintf(&sb, "# conflict (%s) with %s\n", e.Conflict.Kind, e.Conflict.With)
+		}
+
+		for _, member := range append([]PlanEntry{e}, e.Companions...) {
+			prefix := ""
+			if e.Skip || member.Skip {
+				prefix = "# skip: "
+			}
+
+			for _, cmd := range shellCommands(plan, member) {
+				fmt.Fprintf(&sb, "%s%s\n", prefix, cmd)
+			}
+		}
+	}
+
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return fmt.Errorf("unable to write plan: %w", err)
+	}
+
+	return nil
+}
+
+// shellCommands returns the shell commands that place the file for e.
+func shellCommands(plan Plan, e PlanEntry) []string {
+	old := shellQuote(e.Old)
+	dest := shellQuote(e.New)
+	// mv and cp refuse to replace existing files with -n but ln already does by default
+	noClobber, force := " -n", ""
+	if e.Overwrite {
+		noClobber, force = " -f", " -f"
+	}
+
+	// Links point where they would when applying the plan
+	link := func(src string, dst string) string {
+		target, err := linkTarget(src, dst, plan.RelativeLinks)
+		if err != nil {
+			target = absPath(src)
+		}
+
+		return shellQuote(target)
+	}
+
+	cmds := []string{"mkdir -p -- " + shellQuote(filepath.Dir(e.New))}
+	switch plan.Mode {
+	case ModeCopy:
+		cmds = append(cmds, "cp -p"+noClobber+" -- "+old+" "+dest)
+	case ModeReflink:
+		cmds = append(cmds, "cp -p --reflink=always"+noClobber+" -- "+old+" "+dest)
+	case ModeHardlink:
+		cmds = append(cmds, "ln"+force+" -- "+old+" "+dest)
+	case ModeSymlink:
+		cmds = append(cmds, "ln -s"+force+" -- "+link(e.Old, e.New)+" "+dest)
+	case ModeMoveSymlink:
+		cmds = append(cmds, "mv"+noClobber+" -- "+old+" "+dest, "ln -s -- "+link(e.New, e.Old)+" "+old)
+	default:
+		cmds = append(cmds, "mv"+noClobber+" -- "+old+" "+dest)
+	}
+
+	return cmds
+}
+
+// shellQuote quotes s for use as a single word in a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ReadPlan reads a plan written in the JSON format from the file at p.
+func ReadPlan(p string) (Plan, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return Plan{}, fmt.Errorf("unable to read plan: %w", err)
+	}
+
+	var plan Plan
+	if err := json.Unmarshal(b, &plan); err != nil {
+		return Plan{}, fmt.Errorf("unable to parse plan %s: %w", p, err)
+	}
+
+	if plan.Version != planVersion {
+		return Plan{}, fmt.Errorf("unsupported plan version %d", plan.Version)
+	}
+
+	if !slices.Contains(Modes(), string(plan.Mode)) {
+		return Plan{}, fmt.Errorf("unknown mode %s in plan %s", plan.Mode, p)
+	}
+
+	return plan, nil
+}
+
+// PlanRenames returns renames for the entries of plan, which may have been edited since
+// it was written, ready to be passed to RenameFiles. Companions are named after the new
+// name of their file unless their own new name was edited. Entries whose files, or companion
+// files, have changed size or modification time since planning, or whose new names are used
+// by an existing file or another entry, are marked Skip, logged, and returned. Files that
+// are already in place, such as links made by applying the plan before, are skipped.
+func (r *TvRenamer) PlanRenames(plan Plan) ([]Rename, []PlanEntry, error) {
+	var renames []Rename
+	var refused []PlanEntry
+
+	for _, e := range plan.Entries {
+		if e.Old == "" || e.New == "" {
+			return nil, nil, fmt.Errorf("plan entry missing old or new name: %+v", e)
+		}
+
+		op := Rename{Old: e.Old, New: e.New, Skip: e.Skip, Overwrite: e.Overwrite}
+		planned := Rename{Old: e.Old, New: e.Planned}
+		for _, c := range e.Companions {
+			companion := Rename{Old: c.Old, New: c.New, Skip: c.Skip, Overwrite: c.Overwrite}
+			// Overwriting was only planned for the name the companion had then
+			if e.Planned != "" && c.New == companionName(planned, c.Old) && e.New != e.Planned {
+				companion.New = companionName(op, c.Old)
+				companion.Overwrite = false
+			}
+
+			op.Companions = append(op.Companions, companion)
+		}
+
+		if !e.Skip {
+			if err := checkPlanEntry(e); err != nil {
+				r.logger.Warn("skipping changed file", "old", e.Old, "new", e.New, "err", err)
+				op.Skip = true
+				refused = append(refused, e)
+			}
+		}
+
+		renames = append(renames, op)
+	}
+
+	used := make(map[string]string)
+	for i, e := range plan.Entries {
+		op := &renames[i]
+		if op.Skip {
+			continue
+		}
+
+		// Links made by applying the plan before
+		if inPlace(op.Old, op.New, false) {
+			r.logger.Info("already in place", "old", op.Old, "new", op.New)
+			op.Skip = true
+			continue
+		}
+
+		for j := range op.Companions {
+			if c := &op.Companions[j]; !c.Skip && inPlace(c.Old, c.New, false) {
+				c.Skip = true
+			}
+		}
+
+		if member, conflict := planConflict(*op, used); conflict != nil {
+			r.logger.Warn("skipping conflicting file", "old", member.Old, "new", member.New, "kind", conflict.Kind, "with", conflict.With)
+			op.Skip = true
+			op.Conflict = conflict
+			refused = append(refused, e)
+			continue
+		}
+
+		for _, member := range op.group() {
+			used[member.New] = member.Old
+		}
+	}
+
+	return renames, refused, nil
+}
+
+// planConflict returns the first file renamed by op, or one of its companions, whose new
+// name is used by an earlier rename or an existing file that isn't being overwritten, and
+// the conflict. used is the new names of earlier renames mapped to their old names.
+func planConflict(op Rename, used map[string]string) (Rename, *Conflict) {
+	for _, member := range op.group() {
+		if with, ok := used[member.New]; ok {
+			return member, &Conflict{Kind: ConflictDuplicate, With: with}
+		}
+
+		if member.Overwrite || caseRename(member) {
+			continue
+		}
+
+		if _, err := os.Lstat(member.New); err == nil {
+			return member, &Conflict{Kind: ConflictExists, With: member.New}
+		}
+	}
+
+	return Rename{}, nil
+}
+
+// checkPlanEntry returns an error wrapping ErrPlanChanged if the file for e or any
+// of its companions has changed since the plan was made.
+func checkPlanEntry(e PlanEntry) error {
+	for _, member := range append([]PlanEntry{e}, e.Companions...) {
+		info, err := os.Stat(member.Old)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrPlanChanged, err)
+		}
+
+		if info.Size() != member.Size || !info.ModTime().Equal(member.ModTime) {
+			return fmt.Errorf("%w: %s size or modification time differs", ErrPlanChanged, member.Old)
+		}
+	}
+
+	return nil
+}
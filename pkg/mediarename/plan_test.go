@@ -0,0 +1,254 @@
+package mediarename
+
+import (
+	"bytes"
+	"encoding/csv"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTvRenamer_NewPlan(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dest := filepath.Join(dir, "dest")
+	writeTestFile(t, filepath.Join(src, "Show.S01E01.mkv"), "pilot")
+	writeTestFile(t, filepath.Join(src, "Show.S01E01.en.srt"), "subtitles")
+	writeTestFile(t, filepath.Join(src, "Show.S01E02.mkv"), "events")
+
+	client := &fakeClient{show: testShow, episodes: testEpisodes}
+	renamer := NewTvRenamer(client, TvOptions{}, slog.New(slog.DiscardHandler))
+	renames, err := renamer.GenerateNames(mediaFiles(
+		filepath.Join(src, "Show.S01E01.mkv"),
+		filepath.Join(src, "Show.S01E01.en.srt"),
+		filepath.Join(src, "Show.S01E02.mkv"),
+	), dest, "tt1234")
+	RequireNoError(t, err)
+
+	plan, err := renamer.NewPlan(renames)
+	RequireNoError(t, err)
+
+	t.Run("metadata", func(t *testing.T) {
+		RequireEqual(t, ModeMove, plan.Mode)
+		RequireEqual(t, 2, len(plan.Entries))
+		RequireEqual(t, int64(5), plan.Entries[0].Size)
+		RequireEqual(t, "The Show", plan.Entries[0].Match.Show)
+		RequireEqual(t, "Pilot", plan.Entries[0].Match.Episodes[0].Name)
+		RequireEqual(t, 1, len(plan.Entries[0].Companions))
+		RequireEqual(t, int64(9), plan.Entries[0].Companions[0].Size)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		p := filepath.Join(dir, "plan.json")
+		var buf bytes.Buffer
+		RequireNoError(t, WritePlan(&buf, plan, PlanJSON))
+		writeTestFile(t, p, buf.String())
+
+		read, err := ReadPlan(p)
+		RequireNoError(t, err)
+		RequireEqual(t, len(plan.Entries), len(read.Entries))
+		RequireEqual(t, plan.Entries[0].New, read.Entries[0].New)
+		RequireEqual(t, true, plan.Entries[0].ModTime.Equal(read.Entries[0].ModTime))
+		RequireEqual(t, plan.Entries[0].Companions[0].New, read.Entries[0].Companions[0].New)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		RequireNoError(t, WritePlan(&buf, plan, PlanCSV))
+
+		rows, err := csv.NewReader(&buf).ReadAll()
+		RequireNoError(t, err)
+		RequireEqual(t, 4, len(rows))
+		RequireEqual(t, "old", rows[0][0])
+		RequireEqual(t, filepath.Join(src, "Show.S01E01.en.srt"), rows[2][0])
+		RequireEqual(t, filepath.Join(src, "Show.S01E01.mkv"), rows[2][7])
+		RequireEqual(t, "s01e01", rows[2][10])
+	})
+
+	t.Run("shell", func(t *testing.T) {
+		var buf bytes.Buffer
+		RequireNoError(t, WritePlan(&buf, plan, PlanShell))
+
+		script := buf.String()
+		RequireEqual(t, true, strings.HasPrefix(script, "#!/bin/sh\n"))
+		RequireEqual(t, true, strings.Contains(script, "# The Show s01e01 Pilot\n"))
+		RequireEqual(t, true, strings.Contains(script, "mv -n -- '"+filepath.Join(src, "Show.S01E01.mkv")+"' '"+plan.Entries[0].New+"'\n"))
+	})
+}
+
+func TestShellQuote(t *testing.T) {
+	RequireEqual(t, `'it'\''s here'`, shellQuote("it's here"))
+}
+
+func TestTvRenamer_PlanRenames(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "src", "first.mkv")
+	second := filepath.Join(dir, "src", "second.mkv")
+	writeTestFile(t, first, "first")
+	writeTestFile(t, second, "second")
+
+	renamer := NewTvRenamer(nil, TvOptions{Commit: true}, slog.New(slog.DiscardHandler))
+	plan, err := renamer.NewPlan([]Rename{
+		{Old: first, New: filepath.Join(dir, "dest", "one.mkv")},
+		{Old: second, New: filepath.Join(dir, "dest", "two.mkv")},
+	})
+	RequireNoError(t, err)
+
+	// Hand edited new name and a source changed since planning
+	plan.Entries[0].New = filepath.Join(dir, "dest", "edited.mkv")
+	writeTestFile(t, second, "second, but longer")
+
+	renames, refused, err := renamer.PlanRenames(plan)
+	RequireNoError(t, err)
+	RequireEqual(t, 1, len(refused))
+	RequireEqual(t, second, refused[0].Old)
+	RequireEqual(t, false, renames[0].Skip)
+	RequireEqual(t, true, renames[1].Skip)
+
+	RequireNoError(t, renamer.RenameFiles(renames))
+	RequireEqual(t, "first", readTestFile(t, filepath.Join(dir, "dest", "edited.mkv")))
+	_, err = os.Stat(second)
+	RequireNoError(t, err)
+}
+
+func TestTvRenamer_PlanRenamesCompanions(t *testing.T) {
+	dir := t.TempDir()
+	video := filepath.Join(dir, "src", "Show.S01E01.mkv")
+	english := filepath.Join(dir, "src", "Show.S01E01.en.srt")
+	french := filepath.Join(dir, "src", "Show.S01E01.fr.srt")
+	for _, f := range []string{video, english, french} {
+		writeTestFile(t, f, "pilot")
+	}
+
+	renamer := NewTvRenamer(nil, TvOptions{Commit: true}, slog.New(slog.DiscardHandler))
+	plan, err := renamer.NewPlan([]Rename{{
+		Old: video,
+		New: filepath.Join(dir, "dest", "pilot.mkv"),
+		Companions: []Rename{
+			{Old: english, New: filepath.Join(dir, "dest", "pilot.en.srt")},
+			{Old: french, New: filepath.Join(dir, "dest", "pilot.fr.srt")},
+		},
+	}})
+	RequireNoError(t, err)
+	RequireEqual(t, filepath.Join(dir, "dest", "pilot.mkv"), plan.Entries[0].Planned)
+
+	// Unedited companions follow the new name, edited ones keep theirs
+	plan.Entries[0].New = filepath.Join(dir, "dest", "edited.mkv")
+	plan.Entries[0].Companions[1].New = filepath.Join(dir, "dest", "french.srt")
+
+	renames, refused, err := renamer.PlanRenames(plan)
+	RequireNoError(t, err)
+	RequireEqual(t, 0, len(refused))
+	RequireEqual(t, filepath.Join(dir, "dest", "edited.en.srt"), renames[0].Companions[0].New)
+	RequireEqual(t, filepath.Join(dir, "dest", "french.srt"), renames[0].Companions[1].New)
+}
+
+func TestTvRenamer_PlanRenamesConflicts(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "src", "first.mkv")
+	second := filepath.Join(dir, "src", "second.mkv")
+	third := filepath.Join(dir, "src", "third.mkv")
+	existing := filepath.Join(dir, "dest", "existing.mkv")
+	for _, f := range []string{first, second, third, existing} {
+		writeTestFile(t, f, filepath.Base(f))
+	}
+
+	renamer := NewTvRenamer(nil, TvOptions{Commit: true}, slog.New(slog.DiscardHandler))
+	plan, err := renamer.NewPlan([]Rename{
+		{Old: first, New: filepath.Join(dir, "dest", "one.mkv")},
+		{Old: second, New: filepath.Join(dir, "dest", "two.mkv")},
+		{Old: third, New: filepath.Join(dir, "dest", "three.mkv")},
+	})
+	RequireNoError(t, err)
+
+	// Hand edited new names that clash with another entry and an existing file
+	plan.Entries[1].New = filepath.Join(dir, "dest", "one.mkv")
+	plan.Entries[2].New = existing
+
+	renames, refused, err := renamer.PlanRenames(plan)
+	RequireNoError(t, err)
+	RequireEqual(t, 2, len(refused))
+	RequireEqual(t, false, renames[0].Skip)
+	RequireEqual(t, true, renames[1].Skip)
+	RequireEqual(t, ConflictDuplicate, renames[1].Conflict.Kind)
+	RequireEqual(t, first, renames[1].Conflict.With)
+	RequireEqual(t, true, renames[2].Skip)
+	RequireEqual(t, ConflictExists, renames[2].Conflict.Kind)
+
+	RequireNoError(t, renamer.RenameFiles(renames))
+	RequireEqual(t, "first.mkv", readTestFile(t, filepath.Join(dir, "dest", "one.mkv")))
+	RequireEqual(t, "existing.mkv", readTestFile(t, existing))
+}
+
+func TestTvRenamer_PlanRenamesReapply(t *testing.T) {
+	dir := t.TempDir()
+	video := filepath.Join(dir, "src", "Show.S01E01.mkv")
+	subtitle := filepath.Join(dir, "src", "Show.S01E01.en.srt")
+	writeTestFile(t, video, "pilot")
+	writeTestFile(t, subtitle, "subtitles")
+
+	for _, mode := range []Mode{ModeHardlink, ModeSymlink} {
+		t.Run(string(mode), func(t *testing.T) {
+			dest := filepath.Join(dir, string(mode))
+			renamer := NewTvRenamer(nil, TvOptions{Commit: true, Mode: mode}, slog.New(slog.DiscardHandler))
+			plan, err := renamer.NewPlan([]Rename{{
+				Old:        video,
+				New:        filepath.Join(dest, "pilot.mkv"),
+				Companions: []Rename{{Old: subtitle, New: filepath.Join(dest, "pilot.en.srt")}},
+			}})
+			RequireNoError(t, err)
+
+			for range 2 {
+				renames, refused, err := renamer.PlanRenames(plan)
+				RequireNoError(t, err)
+				RequireEqual(t, 0, len(refused))
+				RequireNoError(t, renamer.RenameFiles(renames))
+			}
+
+			renames, _, err := renamer.PlanRenames(plan)
+			RequireNoError(t, err)
+			RequireEqual(t, true, renames[0].Skip)
+			RequireEqual(t, true, renames[0].Conflict == nil)
+			RequireEqual(t, "subtitles", readTestFile(t, filepath.Join(dest, "pilot.en.srt")))
+		})
+	}
+}
+
+func TestWritePlanShell(t *testing.T) {
+	dir := t.TempDir()
+	video := filepath.Join(dir, "src", "Show.S01E01.mkv")
+	subtitle := filepath.Join(dir, "src", "Show.S01E01.en.srt")
+	writeTestFile(t, video, "pilot")
+	writeTestFile(t, subtitle, "subtitles")
+
+	renames := []Rename{{
+		Old:        video,
+		New:        filepath.Join(dir, "dest", "pilot.mkv"),
+		Companions: []Rename{{Old: subtitle, New: filepath.Join(dir, "dest", "pilot.en.srt"), Skip: true}},
+	}}
+
+	t.Run("skipped companion", func(t *testing.T) {
+		renamer := NewTvRenamer(nil, TvOptions{}, slog.New(slog.DiscardHandler))
+		plan, err := renamer.NewPlan(renames)
+		RequireNoError(t, err)
+
+		var buf bytes.Buffer
+		RequireNoError(t, WritePlan(&buf, plan, PlanShell))
+
+		script := buf.String()
+		RequireEqual(t, true, strings.Contains(script, "\nmv -n -- '"+video+"' "))
+		RequireEqual(t, true, strings.Contains(script, "\n# skip: mv -n -- '"+subtitle+"' "))
+	})
+
+	t.Run("relative move and link", func(t *testing.T) {
+		renamer := NewTvRenamer(nil, TvOptions{Mode: ModeMoveSymlink, RelativeLinks: true}, slog.New(slog.DiscardHandler))
+		plan, err := renamer.NewPlan(renames[:1])
+		RequireNoError(t, err)
+
+		var buf bytes.Buffer
+		RequireNoError(t, WritePlan(&buf, plan, PlanShell))
+		RequireEqual(t, true, strings.Contains(buf.String(), "\nln -s -- '../dest/pilot.mkv' '"+video+"'\n"))
+	})
+}
@@ -0,0 +1,275 @@
+package mediarename
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Preview is how planned renames are shown.
+type Preview string
+
+const (
+	// PreviewLog logs a line for each file.
+	PreviewLog Preview = "log"
+	// PreviewTree draws the destination as a directory tree.
+	PreviewTree Preview = "tree"
+)
+
+// Previews returns the names of all supported previews.
+func Previews() []string {
+	return []string{string(PreviewLog), string(PreviewTree)}
+}
+
+// treeStatus is what happens to a file shown in a tree preview.
+type treeStatus string
+
+const (
+	treeNew       treeStatus = "new"
+	treeExisting  treeStatus = "exists"
+	treeOverwrite treeStatus = "overwrite"
+	treeConflict  treeStatus = "conflict"
+)
+
+// treeNode is a file or directory in a tree preview.
+type treeNode struct {
+	name     string
+	children map[string]*treeNode
+	status   treeStatus
+	detail   string
+	size     int64
+}
+
+// treeCounts are the totals for the files in a directory and its subdirectories.
+type treeCounts struct {
+	new       int
+	existing  int
+	conflicts int
+	size      int64
+}
+
+// WriteTree writes the destination as it will look after renames are applied as a
+// directory tree to w. Each placed file is marked as new, overwriting an existing file,
+// or conflicting and left where it is. Files already in the destination directories are
+// marked as existing. Directories show the number of files of each kind within them and
+// the size of new files. Files that weren't matched to an episode, and so aren't renamed,
+// are listed after the tree, followed by samples that were excluded.
+func WriteTree(w io.Writer, dest string, files []MediaFile, samples []Sample, renames []Rename) error {
+	root := &treeNode{name: dest, children: make(map[string]*treeNode)}
+	dirs := make(map[string]struct{})
+	matched := make(map[string]struct{})
+
+	for _, op := range renames {
+		matched[op.Old] = struct{}{}
+		for _, member := range append([]Rename{op}, op.Companions...) {
+			// Skipped files already in place are found with the other existing files
+			dirs[filepath.Dir(member.New)] = struct{}{}
+
+			status, detail := treeNew, ""
+			switch {
+			case member.Conflict != nil && member.Skip:
+				status, detail = treeConflict, fmt.Sprintf("%s with %s", member.Conflict.Kind, member.Conflict.With)
+			case op.Conflict != nil && op.Skip:
+				status, detail = treeConflict, fmt.Sprintf("%s with %s", op.Conflict.Kind, op.Conflict.With)
+			case op.Skip || member.Skip:
+				continue
+			case member.Overwrite:
+				status = treeOverwrite
+			}
+
+			var size int64
+			if info, err := os.Stat(member.Old); err == nil {
+				size = info.Size()
+			}
+
+			root.add(treeParts(dest, member.New), status, detail, size)
+		}
+	}
+
+	for dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("unable to read directory %s: %w", dir, err)
+		}
+
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+
+			p := filepath.Join(dir, e.Name())
+			var size int64
+			if info, err := e.Info(); err == nil {
+				size = info.Size()
+			}
+
+			root.addExisting(treeParts(dest, p), size)
+		}
+	}
+
+	var sb strings.Builder
+	total := root.counts()
+	fmt.Fprintf(&sb, "%s/%s\n", strings.TrimSuffix(dest, string(filepath.Separator)), total)
+	root.write(&sb, "")
+
+	var unmatched []string
+	for _, f := range files {
+		if _, ok := matched[f.Path]; !ok {
+			unmatched = append(unmatched, f.Path)
+		}
+	}
+
+	if len(unmatched) > 0 {
+		sb.WriteString("\nunmatched:\n")
+		for _, p := range unmatched {
+			fmt.Fprintf(&sb, "  %s\n", p)
+		}
+	}
+
+	if len(samples) > 0 {
+		sb.WriteString("\nsamples:\n")
+		for _, s := range samples {
+			fmt.Fprintf(&sb, "  %s (%s)\n", s.Path, s.Reason)
+		}
+	}
+
+	fmt.Fprintf(&sb, "\n%d new, %d existing, %d conflicts, %d unmatched, %d samples, %s to place\n",
+		total.new, total.existing, total.conflicts, len(unmatched), len(samples), formatBytes(total.size))
+
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return fmt.Errorf("unable to write preview: %w", err)
+	}
+
+	return nil
+}
+
+// treeParts splits p into path components relative to dest. Paths outside of dest are
+// kept whole so they are shown at the top of the tree.
+func treeParts(dest string, p string) []string {
+	rel, err := filepath.Rel(dest, p)
+	if err != nil || !isUnder(dest, p) {
+		return []string{p}
+	}
+
+	return strings.Split(rel, string(filepath.Separator))
+}
+
+// add adds a file at the path given by parts below n. A conflict doesn't replace a file
+// already added at the same path.
+func (n *treeNode) add(parts []string, status treeStatus, detail string, size int64) {
+	node := n.dir(parts[:len(parts)-1])
+	name := parts[len(parts)-1]
+	if existing, ok := node.children[name]; ok && status == treeConflict && existing.status != treeConflict {
+		return
+	}
+
+	node.children[name] = &treeNode{name: name, status: status, detail: detail, size: size}
+}
+
+// addExisting adds an existing file at the path given by parts below n unless a file
+// to be placed there was already added.
+func (n *treeNode) addExisting(parts []string, size int64) {
+	node := n.dir(parts[:len(parts)-1])
+	name := parts[len(parts)-1]
+	if _, ok := node.children[name]; ok {
+		return
+	}
+
+	node.children[name] = &treeNode{name: name, status: treeExisting, size: size}
+}
+
+// dir returns the directory at the path given by parts below n, creating it and any
+// parents as needed.
+func (n *treeNode) dir(parts []string) *treeNode {
+	node := n
+	for _, part := range parts {
+		child, ok := node.children[part]
+		if !ok {
+			child = &treeNode{name: part, children: make(map[string]*treeNode)}
+			node.children[part] = child
+		}
+
+		node = child
+	}
+
+	return node
+}
+
+// counts returns the totals for all files below n.
+func (n *treeNode) counts() treeCounts {
+	var c treeCounts
+	switch n.status {
+	case treeNew, treeOverwrite:
+		c.new++
+		c.size += n.size
+	case treeExisting:
+		c.existing++
+	case treeConflict:
+		c.conflicts++
+	}
+
+	for _, child := range n.children {
+		cc := child.counts()
+		c.new += cc.new
+		c.existing += cc.existing
+		c.conflicts += cc.conflicts
+		c.size += cc.size
+	}
+
+	return c
+}
+
+// String formats counts as a summary shown after a directory name.
+func (c treeCounts) String() string {
+	var parts []string
+	if c.new > 0 {
+		parts = append(parts, fmt.Sprintf("%d new", c.new))
+	}
+
+	if c.existing > 0 {
+		parts = append(parts, fmt.Sprintf("%d existing", c.existing))
+	}
+
+	if c.conflicts > 0 {
+		parts = append(parts, fmt.Sprintf("%d conflicts", c.conflicts))
+	}
+
+	if c.size > 0 {
+		parts = append(parts, formatBytes(c.size))
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+
+	return "  (" + strings.Join(parts, ", ") + ")"
+}
+
+// write writes the children of n sorted by name, each line prefixed to draw the tree.
+func (n *treeNode) write(sb *strings.Builder, prefix string) {
+	names := slices.Sorted(maps.Keys(n.children))
+	for i, name := range names {
+		child := n.children[name]
+		branch, indent := "├── ", "│   "
+		if i == len(names)-1 {
+			branch, indent = "└── ", "    "
+		}
+
+		if child.children != nil {
+			fmt.Fprintf(sb, "%s%s%s/%s\n", prefix, branch, child.name, child.counts())
+			child.write(sb, prefix+indent)
+			continue
+		}
+
+		fmt.Fprintf(sb, "%s%s%s  [%s", prefix, branch, child.name, child.status)
+		if child.detail != "" {
+			fmt.Fprintf(sb, ": %s", child.detail)
+		}
+
+		fmt.Fprintf(sb, "] %s\n", formatBytes(child.size))
+	}
+}
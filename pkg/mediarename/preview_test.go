@@ -0,0 +1,54 @@
+package mediarename
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteTree(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dest := filepath.Join(dir, "dest")
+	writeTestFile(t, filepath.Join(src, "Show.S01E01.mkv"), "pilot")
+	writeTestFile(t, filepath.Join(src, "Show.S01E01.en.srt"), "subtitles")
+	writeTestFile(t, filepath.Join(src, "Show.S01E02.mkv"), "events")
+	writeTestFile(t, filepath.Join(src, "Show.S02E09.mkv"), "unknown")
+	writeTestFile(t, filepath.Join(dest, "the_show", "season_01", "the_show-s01e02-events.mkv"), "existing")
+	writeTestFile(t, filepath.Join(dest, "the_show", "season_01", "folder.jpg"), "image")
+
+	files := mediaFiles(
+		filepath.Join(src, "Show.S01E01.mkv"),
+		filepath.Join(src, "Show.S01E01.en.srt"),
+		filepath.Join(src, "Show.S01E02.mkv"),
+		filepath.Join(src, "Show.S02E09.mkv"),
+	)
+
+	client := &fakeClient{show: testShow, episodes: testEpisodes}
+	renamer := NewTvRenamer(client, TvOptions{}, slog.New(slog.DiscardHandler))
+	renames, err := renamer.GenerateNames(files, dest, "tt1234")
+	RequireNoError(t, err)
+
+	var sb strings.Builder
+	samples := []Sample{{Path: filepath.Join(src, "Sample", "show.s01e01.mkv"), Reason: SampleDirectory}}
+	RequireNoError(t, WriteTree(&sb, dest, files, samples, renames))
+
+	expected := dest + "/  (2 new, 1 existing, 1 conflicts, 14 B)\n" +
+		"└── the_show/  (2 new, 1 existing, 1 conflicts, 14 B)\n" +
+		"    └── season_01/  (2 new, 1 existing, 1 conflicts, 14 B)\n" +
+		"        ├── folder.jpg  [exists] 5 B\n" +
+		"        ├── the_show-s01e01-pilot.en.srt  [new] 9 B\n" +
+		"        ├── the_show-s01e01-pilot.mkv  [new] 5 B\n" +
+		"        └── the_show-s01e02-events.mkv  [conflict: exists with " + filepath.Join(dest, "the_show", "season_01", "the_show-s01e02-events.mkv") + "] 6 B\n" +
+		"\n" +
+		"unmatched:\n" +
+		"  " + filepath.Join(src, "Show.S02E09.mkv") + "\n" +
+		"\n" +
+		"samples:\n" +
+		"  " + filepath.Join(src, "Sample", "show.s01e01.mkv") + " (directory)\n" +
+		"\n" +
+		"2 new, 1 existing, 1 conflicts, 1 unmatched, 1 samples, 14 B to place\n"
+
+	RequireEqual(t, expected, sb.String())
+}
@@ -0,0 +1,197 @@
+package mediarename
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxProgressName is the longest file name shown in progress, longer names are shortened.
+const maxProgressName = 24
+
+// Progress periodically writes the number of files and bytes processed by a batch of
+// operations to a writer, usually a terminal, along with the throughput, estimated time
+// remaining, and progress of each file currently being processed.
+type Progress struct {
+	out        io.Writer
+	interval   time.Duration
+	totalFiles int64
+	totalBytes int64
+	doneFiles  atomic.Int64
+	doneBytes  atomic.Int64
+	start      time.Time
+
+	mu      sync.Mutex
+	active  map[*FileProgress]struct{}
+	lastLen int
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewProgress creates a new Progress for the given number of files and bytes. Nothing
+// is written until Start is called.
+func NewProgress(out io.Writer, totalFiles int64, totalBytes int64) *Progress {
+	return &Progress{
+		out:        out,
+		interval:   time.Second,
+		totalFiles: totalFiles,
+		totalBytes: totalBytes,
+		active:     make(map[*FileProgress]struct{}),
+		stop:       make(chan struct{}),
+	}
+}
+
+// Start begins writing progress at a regular interval until Stop is called.
+func (p *Progress) Start() {
+	p.start = time.Now()
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.write(time.Now(), false)
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops writing progress and writes a final line with the completed totals and
+// average throughput.
+func (p *Progress) Stop() {
+	close(p.stop)
+	p.wg.Wait()
+	p.write(time.Now(), true)
+	_, _ = fmt.Fprintln(p.out)
+}
+
+// AddBytes records that n bytes have been processed.
+func (p *Progress) AddBytes(n int64) {
+	p.doneBytes.Add(n)
+}
+
+// SkipBytes removes n bytes that didn't need to be processed, such as files that were
+// renamed rather than copied, from the total so that they don't inflate the throughput.
+func (p *Progress) SkipBytes(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.totalBytes -= n
+}
+
+// FileDone records that a file has been processed.
+func (p *Progress) FileDone() {
+	p.doneFiles.Add(1)
+}
+
+// StartFile begins tracking the progress of a single file of the given size. Bytes
+// written to the returned FileProgress count towards the file and the total.
+func (p *Progress) StartFile(name string, size int64) *FileProgress {
+	f := &FileProgress{progress: p, name: name, size: size}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.active[f] = struct{}{}
+	return f
+}
+
+// write writes a line of progress as of now, replacing the previous line. The final
+// line omits the estimated time remaining and each file.
+func (p *Progress) write(now time.Time, final bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	done := p.doneBytes.Load()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d/%d files, %s/%s", p.doneFiles.Load(), p.totalFiles, formatBytes(done), formatBytes(p.totalBytes))
+
+	elapsed := now.Sub(p.start).Seconds()
+	if elapsed > 0 && done > 0 {
+		rate := float64(done) / elapsed
+		fmt.Fprintf(&sb, ", %s/s", formatBytes(int64(rate)))
+
+		if !final && done < p.totalBytes {
+			eta := time.Duration(float64(p.totalBytes-done) / rate * float64(time.Second))
+			fmt.Fprintf(&sb, ", ETA %s", eta.Round(time.Second))
+		}
+	}
+
+	if !final {
+		files := slices.SortedFunc(maps.Keys(p.active), func(a, b *FileProgress) int {
+			return strings.Compare(a.name, b.name)
+		})
+
+		for _, f := range files {
+			fmt.Fprintf(&sb, " | %s %s/%s", shortName(f.name), formatBytes(f.done.Load()), formatBytes(f.size))
+		}
+	}
+
+	// Pad with spaces to clear anything left over from a longer previous line
+	line := sb.String()
+	padding := max(p.lastLen-len(line), 0)
+	p.lastLen = len(line)
+	_, _ = fmt.Fprintf(p.out, "\r%s%s", line, strings.Repeat(" ", padding))
+}
+
+// FileProgress is the progress of a single file within a batch.
+type FileProgress struct {
+	progress *Progress
+	name     string
+	size     int64
+	done     atomic.Int64
+}
+
+// Write records that len(b) bytes of the file have been processed. It implements
+// io.Writer so that it can be used with io.MultiWriter.
+func (f *FileProgress) Write(b []byte) (int, error) {
+	n := int64(len(b))
+	f.done.Add(n)
+	f.progress.AddBytes(n)
+	return len(b), nil
+}
+
+// Finish stops tracking the file.
+func (f *FileProgress) Finish() {
+	f.progress.mu.Lock()
+	defer f.progress.mu.Unlock()
+
+	delete(f.progress.active, f)
+}
+
+// shortName shortens a file name to at most maxProgressName characters.
+func shortName(name string) string {
+	runes := []rune(name)
+	if len(runes) <= maxProgressName {
+		return name
+	}
+
+	return string(runes[:maxProgressName-3]) + "..."
+}
+
+// formatBytes formats a number of bytes using binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
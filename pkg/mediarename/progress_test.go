@@ -0,0 +1,55 @@
+package mediarename
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgress_Write(t *testing.T) {
+	t.Run("in progress", func(t *testing.T) {
+		var out bytes.Buffer
+		p := NewProgress(&out, 2, 4096)
+		p.start = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		file := p.StartFile("video.mkv", 2048)
+		_, _ = file.Write(make([]byte, 1024))
+		p.write(p.start.Add(time.Second), false)
+
+		RequireEqual(t, "\r0/2 files, 1.0 KiB/4.0 KiB, 1.0 KiB/s, ETA 3s | video.mkv 1.0 KiB/2.0 KiB", out.String())
+	})
+
+	t.Run("final", func(t *testing.T) {
+		var out bytes.Buffer
+		p := NewProgress(&out, 1, 2048)
+		p.start = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		file := p.StartFile("a very long name for a video file.mkv", 2048)
+		_, _ = file.Write(make([]byte, 1024))
+		p.write(p.start.Add(time.Second), false)
+
+		_, _ = file.Write(make([]byte, 1024))
+		file.Finish()
+		p.FileDone()
+		out.Reset()
+		p.write(p.start.Add(2*time.Second), true)
+
+		line := strings.TrimRight(out.String(), " ")
+		RequireEqual(t, "\r1/1 files, 2.0 KiB/2.0 KiB, 1.0 KiB/s", line)
+	})
+
+	t.Run("skipped bytes", func(t *testing.T) {
+		var out bytes.Buffer
+		p := NewProgress(&out, 2, 4096)
+		p.start = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		p.SkipBytes(2048)
+		p.FileDone()
+		file := p.StartFile("video.mkv", 2048)
+		_, _ = file.Write(make([]byte, 1024))
+		p.write(p.start.Add(time.Second), false)
+
+		RequireEqual(t, "\r1/2 files, 1.0 KiB/2.0 KiB, 1.0 KiB/s, ETA 1s | video.mkv 1.0 KiB/2.0 KiB", out.String())
+	})
+}
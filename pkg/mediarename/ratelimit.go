@@ -0,0 +1,64 @@
+package mediarename
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits how often requests are made, or how many bytes are transferred,
+// using a token bucket: up to a burst of tokens can be used at once, after which use is
+// spread out evenly. It is safe for concurrent use.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a RateLimiter that allows n tokens per period, with a burst of
+// up to n tokens.
+func NewRateLimiter(n int64, per time.Duration) *RateLimiter {
+	return &RateLimiter{
+		rate:   float64(n) / per.Seconds(),
+		burst:  float64(n),
+		tokens: float64(n),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a request can be made.
+func (l *RateLimiter) Wait() {
+	l.WaitN(1)
+}
+
+// WaitN blocks until n tokens are available. Tokens are reserved before waiting so
+// that concurrent callers wait their turn.
+func (l *RateLimiter) WaitN(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+
+	l.mu.Unlock()
+	time.Sleep(wait)
+}
+
+// limitedWriter is an io.Writer that limits the rate bytes are written to an underlying
+// writer.
+type limitedWriter struct {
+	w       io.Writer
+	limiter *RateLimiter
+}
+
+func (w limitedWriter) Write(b []byte) (int, error) {
+	w.limiter.WaitN(len(b))
+	return w.w.Write(b)
+}
@@ -0,0 +1,40 @@
+package mediarename
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Wait(t *testing.T) {
+	limiter := NewRateLimiter(2, 100*time.Millisecond)
+	start := time.Now()
+
+	// The first two requests are allowed immediately, the next has to wait for a token
+	for range 3 {
+		limiter.Wait()
+	}
+
+	elapsed := time.Since(start)
+	if elapsed < 40*time.Millisecond {
+		t.Fatalf("expected to wait for rate limit, only waited %s", elapsed)
+	}
+}
+
+func TestCopyFileBandwidthLimit(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.mkv")
+	dst := filepath.Join(dir, "dst.mkv")
+	writeTestFile(t, src, strings.Repeat("x", 2048))
+
+	// The first 1024 bytes are allowed immediately, the rest take half a second
+	limiter := NewRateLimiter(1024, 500*time.Millisecond)
+	start := time.Now()
+	RequireNoError(t, copyFile(src, dst, copyOptions{limiter: limiter}))
+
+	elapsed := time.Since(start)
+	if elapsed < 400*time.Millisecond {
+		t.Fatalf("expected copy to be limited, only took %s", elapsed)
+	}
+}
@@ -0,0 +1,48 @@
+package mediarename
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	resolutionRegex = regexp.MustCompile(`(?i)\b(2160p|1080p|1080i|720p|576p|480p|4k)\b`)
+	sourceRegex     = regexp.MustCompile(`(?i)\b(web-?dl|web-?rip|blu-?ray|bdrip|brrip|hdtv|dvdrip|remux)\b`)
+	codecRegex      = regexp.MustCompile(`(?i)\b(x264|x265|h\.?264|h\.?265|hevc|avc|xvid)\b`)
+	groupRegex      = regexp.MustCompile(`-([A-Za-z0-9]+)$`)
+)
+
+// Release is information about a release parsed from the name of a file, such as
+// "Show.S01E01.1080p.WEB-DL.x264-GROUP.mkv".
+type Release struct {
+	Resolution string
+	Source     string
+	Codec      string
+	Group      string
+}
+
+// ParseRelease extracts release information from the name of a file. Any fields that
+// can't be found are left empty.
+func ParseRelease(file string) Release {
+	base := path.Base(file)
+	stem := strings.TrimSuffix(base, path.Ext(base))
+
+	var r Release
+	if m := resolutionRegex.FindString(stem); m != "" {
+		r.Resolution = strings.ToLower(m)
+	}
+
+	r.Source = sourceRegex.FindString(stem)
+	r.Codec = codecRegex.FindString(stem)
+
+	// Only treat a trailing "-NAME" as a group if there's other release info, otherwise
+	// it's likely to be part of the episode title.
+	if r.Resolution != "" || r.Source != "" || r.Codec != "" {
+		if m := groupRegex.FindStringSubmatch(stem); m != nil {
+			r.Group = m[1]
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,274 @@
+package mediarename
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// errQuit is returned when the user quits reviewing.
+	errQuit = errors.New("quit")
+	// errAcceptAll is returned when the user accepts all remaining renames.
+	errAcceptAll = errors.New("accept all")
+)
+
+// reviewPrompt lists the choices for each file during review.
+const reviewPrompt = "[a]ccept, [s]kip, [e]dit target, [p]ick episode, accept [A]ll remaining, [q]uit and save? "
+
+// Review walks through renames one at a time, reading a choice for each from in and
+// writing prompts to out. Each rename can be accepted, skipped, given a different new
+// name, or renamed for a different episode of the show. Renames skipped because of a
+// conflict are shown too so they can be given a new name, but stay skipped unless they
+// are. The remaining renames can be accepted all at once. Quitting keeps the choices made
+// so far and skips the remaining renames, as does reaching the end of in. Returns the
+// reviewed renames, with skipped renames marked Skip.
+func (r *TvRenamer) Review(renames []Rename, dest string, in io.Reader, out io.Writer) ([]Rename, error) {
+	reviewed := slices.Clone(renames)
+	scanner := bufio.NewScanner(in)
+	var episodes Episodes
+
+	for i := range reviewed {
+		op := &reviewed[i]
+		if op.Skip && op.Conflict == nil {
+			continue
+		}
+
+		err := r.reviewOne(op, i, reviewed, dest, scanner, out, &episodes)
+		if errors.Is(err, errAcceptAll) {
+			r.logger.Info("accepted all remaining files", "count", len(reviewed)-i)
+			break
+		}
+
+		if errors.Is(err, errQuit) {
+			for j := i; j < len(reviewed); j++ {
+				reviewed[j].Skip = true
+			}
+
+			r.logger.Info("quit review, skipping remaining files", "count", len(reviewed)-i)
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return reviewed, nil
+}
+
+// reviewOne prompts for a choice for op until it is accepted or skipped. episodes are
+// the episodes of the show, looked up the first time the user picks an episode. A target
+// that conflicts with an existing file or another rename can't be accepted, including the
+// target of a rename that was skipped because of a conflict, until it is changed.
+func (r *TvRenamer) reviewOne(op *Rename, index int, all []Rename, dest string, scanner *bufio.Scanner, out io.Writer, episodes *Episodes) error {
+	// Other renames may have been given new targets since the conflict was found
+	var conflict *Conflict
+	skipped := op.Conflict
+	if op.Skip {
+		conflict = r.checkTarget(op, all)
+	}
+
+	for {
+		_, _ = fmt.Fprintf(out, "\n[%d/%d] %s\n    -> %s\n", index+1, len(all), op.Old, op.New)
+		for _, c := range op.Companions {
+			if c.Skip && c.Conflict != nil {
+				_, _ = fmt.Fprintf(out, "       %s (conflict (%s) with %s, left in place)\n", c.New, c.Conflict.Kind, c.Conflict.With)
+			} else if !c.Skip {
+				_, _ = fmt.Fprintf(out, "       %s\n", c.New)
+			}
+		}
+
+		if op.Conflict != nil {
+			_, _ = fmt.Fprintf(out, "    conflict (%s) with %s\n", op.Conflict.Kind, op.Conflict.With)
+		}
+
+		if op.Skip {
+			_, _ = fmt.Fprintf(out, "    skipped because of a conflict (%s) with %s\n", skipped.Kind, skipped.With)
+		}
+
+		choice, ok := prompt(scanner, out, reviewPrompt)
+		if !ok {
+			return errQuit
+		}
+
+		switch choice {
+		case "a", "A":
+			if conflict != nil {
+				_, _ = fmt.Fprintln(out, "new target is already in use, edit the target, pick another episode, or skip")
+				continue
+			}
+
+			op.Skip = false
+			if choice == "A" {
+				return errAcceptAll
+			}
+
+			return nil
+		case "s":
+			op.Skip = true
+			r.logger.Info("skip", "old", op.Old, "new", op.New)
+			return nil
+		case "q":
+			return errQuit
+		case "e":
+			target, ok := prompt(scanner, out, "new target: ")
+			if !ok {
+				return errQuit
+			}
+
+			if target != "" {
+				if !filepath.IsAbs(target) {
+					target = filepath.Join(dest, target)
+				}
+
+				conflict = r.retarget(op, target, all)
+			}
+		case "p":
+			picked, err := r.pickEpisode(op, all, dest, scanner, out, episodes)
+			if err != nil {
+				return err
+			}
+
+			if picked {
+				conflict = op.Conflict
+			}
+		default:
+			_, _ = fmt.Fprintf(out, "unknown choice %q\n", choice)
+		}
+	}
+}
+
+// pickEpisode lists the episodes of the show and renames op for the one chosen. Returns
+// true if an episode was chosen.
+func (r *TvRenamer) pickEpisode(op *Rename, all []Rename, dest string, scanner *bufio.Scanner, out io.Writer, episodes *Episodes) (bool, error) {
+	if op.Show == nil {
+		_, _ = fmt.Fprintln(out, "no show metadata for this file")
+		return false, nil
+	}
+
+	if *episodes == nil {
+		list, err := r.client.Episodes(op.Show)
+		if err != nil {
+			return false, fmt.Errorf("episode lookup error show %s (%d): %w", op.Show.Name, op.Show.ID, err)
+		}
+
+		if !r.opts.Language.IsZero() {
+			_, list = r.localize(op.Show, list)
+		}
+
+		*episodes = list
+	}
+
+	for i, e := range *episodes {
+		_, _ = fmt.Fprintf(out, "%4d) s%02de%02d %s\n", i+1, e.Season, e.Number, e.Name)
+	}
+
+	choice, ok := prompt(scanner, out, "episode number: ")
+	if !ok || choice == "" {
+		return false, nil
+	}
+
+	n, err := strconv.Atoi(choice)
+	if err != nil || n < 1 || n > len(*episodes) {
+		_, _ = fmt.Fprintf(out, "no episode %q\n", choice)
+		return false, nil
+	}
+
+	picked := Episodes{(*episodes)[n-1]}
+	var companions []string
+	for _, c := range op.Companions {
+		companions = append(companions, c.Old)
+	}
+
+	newName, truncated, err := r.nameFromEpisodes(op.Old, companions, dest, op.Show, picked, absoluteNumbers(*episodes))
+	if err != nil {
+		return false, fmt.Errorf("unable to generate new name for %s: %w", op.Old, err)
+	}
+
+	op.Episodes = picked
+	op.Extra = ""
+	op.Truncated = truncated
+	r.retarget(op, newName, all)
+	return true, nil
+}
+
+// retarget changes the new name of op and its companions. Returns the conflict, also set
+// on op, if the new name is already used by an existing file or by another rename or its
+// companions. Companions whose new names are already used are skipped.
+func (r *TvRenamer) retarget(op *Rename, target string, all []Rename) *Conflict {
+	op.New = target
+	conflict := r.checkTarget(op, all)
+	r.logger.Info("changed target", "old", op.Old, "new", op.New)
+	return conflict
+}
+
+// checkTarget names the companions of op after its new name and returns the conflict,
+// also set on op, if the new name is already used by an existing file or by another
+// rename or its companions. Companions whose new names are already used are skipped.
+func (r *TvRenamer) checkTarget(op *Rename, all []Rename) *Conflict {
+	op.Conflict = nil
+	op.Overwrite = false
+
+	used := make(map[string]string)
+	for _, other := range all {
+		if other.Old == op.Old || other.Skip {
+			continue
+		}
+
+		for _, member := range other.group() {
+			used[member.New] = member.Old
+		}
+	}
+
+	// conflict returns why p can't be used as a new name, if it can't
+	conflict := func(p string) *Conflict {
+		if with, ok := used[p]; ok {
+			return &Conflict{Kind: ConflictDuplicate, With: with}
+		}
+
+		if _, err := os.Lstat(p); err == nil {
+			return &Conflict{Kind: ConflictExists, With: p}
+		}
+
+		return nil
+	}
+
+	op.Conflict = conflict(op.New)
+	used[op.New] = op.Old
+
+	op.Companions = slices.Clone(op.Companions)
+	for i := range op.Companions {
+		c := &op.Companions[i]
+		c.New = companionName(*op, c.Old)
+		c.Overwrite = false
+		c.Conflict = conflict(c.New)
+		c.Skip = c.Conflict != nil
+		if c.Conflict != nil {
+			r.logger.Warn("conflict", "kind", c.Conflict.Kind, "old", c.Old, "new", c.New, "with", c.Conflict.With, "skip", true)
+			continue
+		}
+
+		used[c.New] = c.Old
+	}
+
+	return op.Conflict
+}
+
+// prompt writes text to out and returns the next line read, trimmed of spaces. Returns
+// false if there is no more input.
+func prompt(scanner *bufio.Scanner, out io.Writer, text string) (string, bool) {
+	_, _ = fmt.Fprint(out, text)
+	if !scanner.Scan() {
+		_, _ = fmt.Fprintln(out)
+		return "", false
+	}
+
+	return strings.TrimSpace(scanner.Text()), true
+}
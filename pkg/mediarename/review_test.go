@@ -0,0 +1,135 @@
+package mediarename
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestTvRenamer_Review(t *testing.T) {
+	client := &fakeClient{show: testShow, episodes: testEpisodes}
+	renamer := NewTvRenamer(client, TvOptions{}, slog.New(slog.DiscardHandler))
+
+	generate := func(t *testing.T) []Rename {
+		renames, err := renamer.GenerateNames(mediaFiles(
+			"src/Show.S01E01.mkv",
+			"src/Show.S01E01.en.srt",
+			"src/Show.S01E02.mkv",
+		), "dest", "tt1234")
+
+		RequireNoError(t, err)
+		RequireEqual(t, 2, len(renames))
+		return renames
+	}
+
+	t.Run("accept and skip", func(t *testing.T) {
+		renames := generate(t)
+		reviewed, err := renamer.Review(renames, "dest", strings.NewReader("s\na\n"), io.Discard)
+
+		RequireNoError(t, err)
+		RequireEqual(t, true, reviewed[0].Skip)
+		RequireEqual(t, false, reviewed[1].Skip)
+		RequireEqual(t, false, renames[0].Skip)
+	})
+
+	t.Run("edit target", func(t *testing.T) {
+		renames := generate(t)
+		reviewed, err := renamer.Review(renames, "dest", strings.NewReader("e\nother/pilot.mkv\na\nA\n"), io.Discard)
+
+		RequireNoError(t, err)
+		RequireEqual(t, "dest/other/pilot.mkv", reviewed[0].New)
+		RequireEqual(t, "dest/other/pilot.en.srt", reviewed[0].Companions[0].New)
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e01-pilot.en.srt", renames[0].Companions[0].New)
+		RequireEqual(t, false, reviewed[1].Skip)
+	})
+
+	t.Run("pick episode", func(t *testing.T) {
+		renames := generate(t)
+		var out strings.Builder
+		reviewed, err := renamer.Review(renames, "dest", strings.NewReader("a\np\n3\na\n"), &out)
+
+		RequireNoError(t, err)
+		RequireEqual(t, true, strings.Contains(out.String(), "   3) s01e123 Finale"))
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e123-finale.mkv", reviewed[1].New)
+		RequireEqual(t, 3, reviewed[1].Episodes[0].ID)
+		RequireEqual(t, true, reviewed[1].Conflict == nil)
+		RequireEqual(t, false, reviewed[1].Skip)
+	})
+
+	t.Run("pick conflicting episode", func(t *testing.T) {
+		renames := generate(t)
+		var out strings.Builder
+		reviewed, err := renamer.Review(renames, "dest", strings.NewReader("a\np\n1\na\nA\n"), &out)
+
+		RequireNoError(t, err)
+		RequireEqual(t, true, strings.Contains(out.String(), "new target is already in use"))
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e01-pilot.mkv", reviewed[1].New)
+		RequireEqual(t, ConflictDuplicate, reviewed[1].Conflict.Kind)
+		RequireEqual(t, "src/Show.S01E01.mkv", reviewed[1].Conflict.With)
+		RequireEqual(t, true, reviewed[1].Skip)
+	})
+
+	t.Run("edit target to a companion target", func(t *testing.T) {
+		renames := generate(t)
+		input := "a\ne\nthe_show/season_01/the_show-s01e01-pilot.en.srt\na\ns\n"
+		reviewed, err := renamer.Review(renames, "dest", strings.NewReader(input), io.Discard)
+
+		RequireNoError(t, err)
+		RequireEqual(t, ConflictDuplicate, reviewed[1].Conflict.Kind)
+		RequireEqual(t, "src/Show.S01E01.en.srt", reviewed[1].Conflict.With)
+		RequireEqual(t, true, reviewed[1].Skip)
+	})
+
+	t.Run("skipped duplicates", func(t *testing.T) {
+		duplicates := func(t *testing.T) []Rename {
+			renames, err := renamer.GenerateNames(mediaFiles("src/a/Show.S01E01.mkv", "src/b/Show.S01E01.mkv"), "dest", "tt1234")
+
+			RequireNoError(t, err)
+			RequireEqual(t, true, renames[0].Skip)
+			RequireEqual(t, true, renames[1].Skip)
+			return renames
+		}
+
+		t.Run("pick episode", func(t *testing.T) {
+			var out strings.Builder
+			reviewed, err := renamer.Review(duplicates(t), "dest", strings.NewReader("p\n2\na\na\n"), &out)
+
+			RequireNoError(t, err)
+			RequireEqual(t, true, strings.Contains(out.String(), "skipped because of a conflict (duplicate) with src/b/Show.S01E01.mkv"))
+			RequireEqual(t, "dest/the_show/season_01/the_show-s01e02-events.mkv", reviewed[0].New)
+			RequireEqual(t, false, reviewed[0].Skip)
+			RequireEqual(t, "dest/the_show/season_01/the_show-s01e01-pilot.mkv", reviewed[1].New)
+			RequireEqual(t, false, reviewed[1].Skip)
+		})
+
+		t.Run("keep skipped", func(t *testing.T) {
+			var out strings.Builder
+			reviewed, err := renamer.Review(duplicates(t), "dest", strings.NewReader("a\na\ns\n"), &out)
+
+			RequireNoError(t, err)
+			RequireEqual(t, true, strings.Contains(out.String(), "new target is already in use"))
+			RequireEqual(t, false, reviewed[0].Skip)
+			RequireEqual(t, true, reviewed[1].Skip)
+			RequireEqual(t, "src/a/Show.S01E01.mkv", reviewed[1].Conflict.With)
+		})
+	})
+
+	t.Run("quit", func(t *testing.T) {
+		renames := generate(t)
+		reviewed, err := renamer.Review(renames, "dest", strings.NewReader("q\n"), io.Discard)
+
+		RequireNoError(t, err)
+		RequireEqual(t, true, reviewed[0].Skip)
+		RequireEqual(t, true, reviewed[1].Skip)
+	})
+
+	t.Run("end of input", func(t *testing.T) {
+		renames := generate(t)
+		reviewed, err := renamer.Review(renames, "dest", strings.NewReader("a\n"), io.Discard)
+
+		RequireNoError(t, err)
+		RequireEqual(t, false, reviewed[0].Skip)
+		RequireEqual(t, true, reviewed[1].Skip)
+	})
+}
@@ -0,0 +1,137 @@
+package mediarename
+
+import (
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultSampleRatio is the fraction of the median size of videos below which a video
+// is considered a sample.
+const DefaultSampleRatio = 0.1
+
+// SampleReason is why a file was considered a sample.
+type SampleReason string
+
+const (
+	// SampleName means the name of the file marks it as a sample.
+	SampleName SampleReason = "name"
+	// SampleDirectory means the file is in a "Sample" directory.
+	SampleDirectory SampleReason = "directory"
+	// SampleSize means the file is much smaller than other videos.
+	SampleSize SampleReason = "size"
+)
+
+var (
+	// sampleRegex matches names that start or end with "sample" as a separate word,
+	// e.g. "sample", "sample-show.s01e01", or "show.s01e01.sample".
+	sampleRegex = regexp.MustCompile(`(?i)(^|[ ._-])sample$|^sample([ ._-]|$)`)
+)
+
+// Sample is a file excluded from renaming because it looks like a sample of a video
+// rather than the video itself.
+type Sample struct {
+	Path   string
+	Reason SampleReason
+}
+
+// isSampleName returns true if the name of the file at p, without its extension, marks
+// it as a sample.
+func isSampleName(p string) bool {
+	stem := trimExt(path.Base(p))
+	if IsSubtitle(p) {
+		stem, _ = ParseSubtitle(p)
+	}
+
+	return sampleRegex.MatchString(stem)
+}
+
+// inSampleDir returns true if the file at p is in a directory named "sample" or
+// "samples" below base.
+func inSampleDir(base string, p string) bool {
+	rel, err := filepath.Rel(base, filepath.Dir(p))
+	if err != nil || rel == "." {
+		return false
+	}
+
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if strings.EqualFold(part, "sample") || strings.EqualFold(part, "samples") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitSamples returns files that aren't samples by name or directory and, separately,
+// those that are.
+func splitSamples(base string, files []string) ([]string, []Sample) {
+	var kept []string
+	var samples []Sample
+	for _, p := range files {
+		switch {
+		case inSampleDir(base, p):
+			samples = append(samples, Sample{Path: p, Reason: SampleDirectory})
+		case isSampleName(p):
+			samples = append(samples, Sample{Path: p, Reason: SampleName})
+		default:
+			kept = append(kept, p)
+		}
+	}
+
+	return kept, samples
+}
+
+// splitSmallSamples returns the files whose videos are at least ratio times the median
+// size of all videos and, separately, the videos and companions that are smaller. Only
+// videos are compared, subtitles without a video are always kept. If extras is true, files
+// that look like extras and have no episode number, the files renamed as extras, are always
+// kept and aren't compared either, since trailers and other extras are usually much smaller
+// than episodes. sizes are the sizes of files by path.
+func splitSmallSamples(files []MediaFile, sizes map[string]int64, ratio float64, extras bool) ([]MediaFile, []Sample) {
+	compared := func(f MediaFile) bool {
+		if IsSubtitle(f.Path) {
+			return false
+		}
+
+		// Directory names can look like extras too, such as for a show named "Extras"
+		if !extras || hasEpisodeTag(f.Path) {
+			return true
+		}
+
+		_, extra := ClassifyExtra(f.Path)
+		return !extra
+	}
+
+	var videos []int64
+	for _, f := range files {
+		if compared(f) {
+			videos = append(videos, sizes[f.Path])
+		}
+	}
+
+	if len(videos) < 2 || ratio <= 0 {
+		return files, nil
+	}
+
+	slices.Sort(videos)
+	threshold := int64(float64(videos[len(videos)/2]) * ratio)
+
+	var kept []MediaFile
+	var samples []Sample
+	for _, f := range files {
+		if !compared(f) || sizes[f.Path] >= threshold {
+			kept = append(kept, f)
+			continue
+		}
+
+		samples = append(samples, Sample{Path: f.Path, Reason: SampleSize})
+		for _, c := range f.Companions {
+			samples = append(samples, Sample{Path: c, Reason: SampleSize})
+		}
+	}
+
+	return kept, samples
+}
@@ -0,0 +1,114 @@
+package mediarename
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsSampleName(t *testing.T) {
+	cases := []struct {
+		path   string
+		sample bool
+	}{
+		{"src/sample.mkv", true},
+		{"src/Sample.mkv", true},
+		{"src/Show.S01E01.sample.mkv", true},
+		{"src/show-s01e01-sample.mkv", true},
+		{"src/sample-show.s01e01.mkv", true},
+		{"src/Show.S01E01.sample.en.srt", true},
+		{"src/Show.S01E01.mkv", false},
+		{"src/Show.S01E05.Free.Samples.720p.mkv", false},
+		{"src/Show.S01E05.Sampler.mkv", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			RequireEqual(t, c.sample, isSampleName(c.path))
+		})
+	}
+}
+
+func TestTvRenamer_FindFilesSamples(t *testing.T) {
+	dir := t.TempDir()
+	episode := strings.Repeat("x", 1000)
+	writeTestFile(t, filepath.Join(dir, "Show.S01E01.mkv"), episode)
+	writeTestFile(t, filepath.Join(dir, "Show.S01E02.mkv"), episode)
+	writeTestFile(t, filepath.Join(dir, "Show.S01E03.mkv"), "tiny")
+	writeTestFile(t, filepath.Join(dir, "Show.S01E03.nfo"), "<episodedetails/>")
+	writeTestFile(t, filepath.Join(dir, "Show.S01E01.sample.mkv"), episode)
+	writeTestFile(t, filepath.Join(dir, "Sample", "show.s01e02.mkv"), episode)
+	writeTestFile(t, filepath.Join(dir, "Show.S01E01.en.srt"), "subtitles")
+
+	t.Run("excluded", func(t *testing.T) {
+		renamer := NewTvRenamer(nil, TvOptions{}, slog.New(slog.DiscardHandler))
+		files, samples, err := renamer.FindFiles(dir, map[string]struct{}{".mkv": {}, ".srt": {}})
+
+		RequireNoError(t, err)
+		RequireEqual(t, 2, len(files))
+		RequireEqual(t, filepath.Join(dir, "Show.S01E01.mkv"), files[0].Path)
+		RequireEqual(t, 1, len(files[0].Companions))
+		RequireEqual(t, filepath.Join(dir, "Show.S01E02.mkv"), files[1].Path)
+
+		RequireEqual(t, 4, len(samples))
+		RequireEqual(t, Sample{Path: filepath.Join(dir, "Sample", "show.s01e02.mkv"), Reason: SampleDirectory}, samples[0])
+		RequireEqual(t, Sample{Path: filepath.Join(dir, "Show.S01E01.sample.mkv"), Reason: SampleName}, samples[1])
+		RequireEqual(t, Sample{Path: filepath.Join(dir, "Show.S01E03.mkv"), Reason: SampleSize}, samples[2])
+		RequireEqual(t, Sample{Path: filepath.Join(dir, "Show.S01E03.nfo"), Reason: SampleSize}, samples[3])
+	})
+
+	t.Run("size check disabled", func(t *testing.T) {
+		renamer := NewTvRenamer(nil, TvOptions{SampleRatio: -1}, slog.New(slog.DiscardHandler))
+		files, samples, err := renamer.FindFiles(dir, map[string]struct{}{".mkv": {}, ".srt": {}})
+
+		RequireNoError(t, err)
+		RequireEqual(t, 3, len(files))
+		RequireEqual(t, 2, len(samples))
+	})
+
+	t.Run("kept", func(t *testing.T) {
+		renamer := NewTvRenamer(nil, TvOptions{KeepSamples: true}, slog.New(slog.DiscardHandler))
+		files, samples, err := renamer.FindFiles(dir, map[string]struct{}{".mkv": {}, ".srt": {}})
+
+		RequireNoError(t, err)
+		RequireEqual(t, 5, len(files))
+		RequireEqual(t, 0, len(samples))
+	})
+}
+
+func TestTvRenamer_FindFilesSamplesAndExtras(t *testing.T) {
+	dir := t.TempDir()
+	episode := strings.Repeat("x", 1000)
+	writeTestFile(t, filepath.Join(dir, "Show.S01E01.mkv"), episode)
+	writeTestFile(t, filepath.Join(dir, "Show.S01E02.mkv"), episode)
+	writeTestFile(t, filepath.Join(dir, "Show.S01E03.mkv"), "tiny")
+	writeTestFile(t, filepath.Join(dir, "Show.S01.Trailer.mkv"), "tiny")
+	writeTestFile(t, filepath.Join(dir, "Extras", "Interview.mkv"), "tiny")
+
+	renamer := NewTvRenamer(nil, TvOptions{Extras: true}, slog.New(slog.DiscardHandler))
+	files, samples, err := renamer.FindFiles(dir, map[string]struct{}{".mkv": {}})
+
+	RequireNoError(t, err)
+	RequireEqual(t, 4, len(files))
+	RequireEqual(t, filepath.Join(dir, "Extras", "Interview.mkv"), files[0].Path)
+	RequireEqual(t, filepath.Join(dir, "Show.S01.Trailer.mkv"), files[1].Path)
+	RequireEqual(t, 1, len(samples))
+	RequireEqual(t, Sample{Path: filepath.Join(dir, "Show.S01E03.mkv"), Reason: SampleSize}, samples[0])
+}
+
+func TestTvRenamer_FindFilesSamplesExtrasShow(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Trailer Park Boys")
+	episode := strings.Repeat("x", 1000)
+	writeTestFile(t, filepath.Join(dir, "Show.S01E01.mkv"), episode)
+	writeTestFile(t, filepath.Join(dir, "Show.S01E02.mkv"), episode)
+	writeTestFile(t, filepath.Join(dir, "Show.S01E03.mkv"), "tiny")
+
+	renamer := NewTvRenamer(nil, TvOptions{Extras: true}, slog.New(slog.DiscardHandler))
+	files, samples, err := renamer.FindFiles(dir, map[string]struct{}{".mkv": {}})
+
+	RequireNoError(t, err)
+	RequireEqual(t, 2, len(files))
+	RequireEqual(t, 1, len(samples))
+	RequireEqual(t, Sample{Path: filepath.Join(dir, "Show.S01E03.mkv"), Reason: SampleSize}, samples[0])
+}
@@ -0,0 +1,121 @@
+package mediarename
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Profile is a set of rules for names that are valid on a particular kind of filesystem.
+type Profile string
+
+const (
+	// ProfilePosix allows any character except "/" in names.
+	ProfilePosix Profile = "posix"
+	// ProfileWindows disallows characters, names, and trailing dots or spaces that are
+	// invalid on Windows, including for files served to Windows clients over SMB.
+	ProfileWindows Profile = "windows"
+	// ProfileFat32 has the same restrictions on names as ProfileWindows and is used for
+	// removable drives formatted as FAT32 or exFAT.
+	ProfileFat32 Profile = "fat32"
+)
+
+var (
+	// windowsReplacer replaces characters that are not allowed in names on Windows.
+	windowsReplacer = strings.NewReplacer(
+		`<`, "(",
+		`>`, ")",
+		`:`, "-",
+		`"`, "'",
+		`\`, "_",
+		`|`, "-",
+		`?`, "",
+		`*`, "",
+	)
+
+	// windowsReserved are names of devices on Windows that can't be used as the name of
+	// a file or directory, with or without an extension.
+	windowsReserved = map[string]struct{}{
+		"CON": {}, "PRN": {}, "AUX": {}, "NUL": {},
+		"COM1": {}, "COM2": {}, "COM3": {}, "COM4": {}, "COM5": {}, "COM6": {}, "COM7": {}, "COM8": {}, "COM9": {},
+		"LPT1": {}, "LPT2": {}, "LPT3": {}, "LPT4": {}, "LPT5": {}, "LPT6": {}, "LPT7": {}, "LPT8": {}, "LPT9": {},
+	}
+)
+
+// Profiles returns the names of all filesystem profiles.
+func Profiles() []string {
+	return []string{string(ProfilePosix), string(ProfileWindows), string(ProfileFat32)}
+}
+
+// Sanitizer cleans up names generated from metadata so that they are valid on the
+// destination filesystem.
+type Sanitizer struct {
+	// Profile is the filesystem that names must be valid on.
+	Profile Profile
+	// Separator replaces spaces and slashes when using the sanitize template function.
+	Separator string
+	// PreserveCase keeps the case of names when using the sanitize template function
+	// instead of making them lowercase.
+	PreserveCase bool
+	// ASCII transliterates names to ASCII, removing accents and other marks. Letters
+	// of scripts without an ASCII equivalent are kept.
+	ASCII bool
+	// NFC normalizes names to Unicode normalization form C.
+	NFC bool
+}
+
+// DefaultSanitizer returns a Sanitizer for POSIX filesystems that separates words with
+// underscores and lowercases names.
+func DefaultSanitizer() Sanitizer {
+	return Sanitizer{Profile: ProfilePosix, Separator: "_"}
+}
+
+// Sanitize turns val into a name with words separated by the separator, without
+// punctuation, and lowercased unless preserving case. This is the sanitize template
+// function.
+func (s Sanitizer) Sanitize(val string) string {
+	val = strings.ReplaceAll(val, " ", s.Separator)
+	val = strings.ReplaceAll(val, "/", s.Separator)
+	val = strings.ReplaceAll(val, pathSep, s.Separator)
+	val = strings.ReplaceAll(val, ":", "")
+	val = strings.ReplaceAll(val, "'", "")
+	val = strings.ReplaceAll(val, ",", "")
+	val = strings.ReplaceAll(val, "&", "and")
+	if !s.PreserveCase {
+		val = strings.ToLower(val)
+	}
+
+	return val
+}
+
+// Segment makes val valid as a single file or directory name on the destination
+// filesystem. It is applied to every part of the path generated by a template.
+func (s Sanitizer) Segment(val string) string {
+	val = strings.ReplaceAll(val, pathSep, "_")
+	if s.NFC || s.ASCII {
+		val = normalizeNFC(val)
+	}
+
+	if s.ASCII {
+		val = transliterateASCII(val)
+	}
+
+	val = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+
+		return r
+	}, val)
+
+	if s.Profile == ProfileWindows || s.Profile == ProfileFat32 {
+		val = windowsReplacer.Replace(val)
+		val = strings.TrimRight(val, ". ")
+
+		stem, _, _ := strings.Cut(val, ".")
+		if _, ok := windowsReserved[strings.ToUpper(strings.TrimSpace(stem))]; ok {
+			val = stem + "_" + val[len(stem):]
+		}
+	}
+
+	return strings.TrimSpace(val)
+}
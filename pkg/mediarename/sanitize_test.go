@@ -0,0 +1,59 @@
+package mediarename
+
+import (
+	"testing"
+)
+
+func TestSanitizer_Sanitize(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		RequireEqual(t, "the_show_law_and_order", DefaultSanitizer().Sanitize("The Show: Law & Order"))
+	})
+
+	t.Run("preserve case and separator", func(t *testing.T) {
+		san := Sanitizer{Separator: ".", PreserveCase: true}
+
+		RequireEqual(t, "Its.A.Show", san.Sanitize("It's A Show"))
+	})
+}
+
+func TestSanitizer_Segment(t *testing.T) {
+	t.Run("posix", func(t *testing.T) {
+		san := DefaultSanitizer()
+
+		RequireEqual(t, "What? A Show: Part 1.", san.Segment(" What? A Show: Part 1. "))
+	})
+
+	t.Run("windows characters", func(t *testing.T) {
+		san := Sanitizer{Profile: ProfileWindows}
+
+		RequireEqual(t, "What A Show- 'Part' (1)", san.Segment(`What? A Show: "Part" <1>*`))
+	})
+
+	t.Run("windows trailing dots and spaces", func(t *testing.T) {
+		san := Sanitizer{Profile: ProfileWindows}
+
+		RequireEqual(t, "To Be Continued", san.Segment("To Be Continued... "))
+	})
+
+	t.Run("windows reserved names", func(t *testing.T) {
+		san := Sanitizer{Profile: ProfileFat32}
+
+		RequireEqual(t, "con_", san.Segment("con"))
+		RequireEqual(t, "AUX_.part1", san.Segment("AUX.part1"))
+		RequireEqual(t, "Console", san.Segment("Console"))
+	})
+
+	t.Run("nfc", func(t *testing.T) {
+		san := Sanitizer{NFC: true}
+
+		RequireEqual(t, "Pok\u00e9mon \u1ec6 \u0439", san.Segment("Poke\u0301mon E\u0323\u0302 \u0438\u0306"))
+	})
+
+	t.Run("ascii", func(t *testing.T) {
+		san := Sanitizer{ASCII: true}
+
+		RequireEqual(t, "Pokemon Strasse - AEon Lodz", san.Segment("Pokémon Straße – Æon Łódź"))
+		RequireEqual(t, "Kukhnya - Ellinika Ellinika", san.Segment("Кухня - Ελληνικά Elliniká"))
+		RequireEqual(t, "Shingeki no Kyojin 進撃の巨人 2", san.Segment("Shingeki no Kyojin 進撃の巨人 ★2"))
+	})
+}
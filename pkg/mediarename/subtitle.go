@@ -0,0 +1,167 @@
+package mediarename
+
+import (
+	"path"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+var (
+	// subtitleExtensions are extensions of subtitle files that are placed next to the
+	// video they belong to.
+	subtitleExtensions = map[string]struct{}{
+		".ass": {},
+		".idx": {},
+		".srt": {},
+		".ssa": {},
+		".sub": {},
+		".vtt": {},
+	}
+
+	// iso6392 maps ISO 639-2 language codes, both bibliographic and terminology forms,
+	// to the equivalent ISO 639-1 code.
+	iso6392 = map[string]string{
+		"ara": "ar",
+		"chi": "zh", "zho": "zh",
+		"cze": "cs", "ces": "cs",
+		"dan": "da",
+		"dut": "nl", "nld": "nl",
+		"eng": "en",
+		"est": "et",
+		"fin": "fi",
+		"fre": "fr", "fra": "fr",
+		"ger": "de", "deu": "de",
+		"gre": "el", "ell": "el",
+		"heb": "he",
+		"hin": "hi",
+		"hun": "hu",
+		"ita": "it",
+		"jpn": "ja",
+		"kor": "ko",
+		"lav": "lv",
+		"lit": "lt",
+		"nob": "nb",
+		"nor": "no",
+		"pol": "pl",
+		"por": "pt",
+		"rum": "ro", "ron": "ro",
+		"rus": "ru",
+		"slo": "sk", "slk": "sk",
+		"spa": "es",
+		"swe": "sv",
+		"tha": "th",
+		"tur": "tr",
+		"ukr": "uk",
+		"vie": "vi",
+	}
+)
+
+// IsSubtitle returns true if file is a subtitle, based on its extension.
+func IsSubtitle(file string) bool {
+	_, ok := subtitleExtensions[strings.ToLower(path.Ext(file))]
+	return ok
+}
+
+// Subtitle is the language and flags of a subtitle file, parsed from qualifiers before
+// its extension such as "show.en.forced.srt" or "show.pt-BR.sdh.srt".
+type Subtitle struct {
+	// Language is the language of the subtitles, if known.
+	Language Language
+	// Forced subtitles only cover dialog that isn't in the language of the audio.
+	Forced bool
+	// SDH subtitles are for the deaf and hard of hearing and include non-dialog audio.
+	SDH bool
+}
+
+// Suffix returns the qualifiers for the subtitle in normalized form to be placed
+// between the name of the video and the subtitle extension, e.g. ".en.forced".
+func (s Subtitle) Suffix() string {
+	var sb strings.Builder
+	if !s.Language.IsZero() {
+		sb.WriteString("." + s.Language.String())
+	}
+
+	if s.SDH {
+		sb.WriteString(".sdh")
+	}
+
+	if s.Forced {
+		sb.WriteString(".forced")
+	}
+
+	return sb.String()
+}
+
+// ParseSubtitle splits the name of a subtitle file, without its directory, into the
+// stem it shares with the video it belongs to and its language and flags. Qualifiers
+// are removed from the end of the name until one isn't recognized.
+func ParseSubtitle(file string) (string, Subtitle) {
+	parts := subtitleParts(file)
+	for i := range parts {
+		if sub, ok := parseSubtitleQualifiers(parts[i+1:]); ok {
+			return strings.Join(parts[:i+1], "."), sub
+		}
+	}
+
+	return strings.Join(parts, "."), Subtitle{}
+}
+
+// subtitleParts returns the "." separated parts of the name of a subtitle file without
+// its directory and extension.
+func subtitleParts(file string) []string {
+	base := path.Base(file)
+	return strings.Split(strings.TrimSuffix(base, path.Ext(base)), ".")
+}
+
+// parseSubtitleQualifiers parses the parts of a subtitle file name following the stem,
+// returning false if any of them isn't a language, "forced", or "sdh" flag, or if there
+// is more than one language.
+func parseSubtitleQualifiers(parts []string) (Subtitle, bool) {
+	var sub Subtitle
+	for _, part := range parts {
+		switch strings.ToLower(part) {
+		case "forced":
+			sub.Forced = true
+		case "sdh", "cc":
+			sub.SDH = true
+		default:
+			lang, ok := normalizeLanguage(part)
+			if !ok || !sub.Language.IsZero() {
+				return Subtitle{}, false
+			}
+
+			sub.Language = lang
+		}
+	}
+
+	return sub, true
+}
+
+// normalizeLanguage parses tag as an ISO 639-1 or ISO 639-2 language code with an
+// optional region, returning it in ISO 639-1 form. Returns false if the language isn't
+// known. Three letter codes are only accepted for languages with an ISO 639-1 code so
+// that ordinary words in file names aren't mistaken for rarer languages.
+func normalizeLanguage(tag string) (Language, bool) {
+	lang := ParseLanguage(tag)
+	if code, ok := iso6392[lang.Code]; ok {
+		lang.Code = code
+	}
+
+	if len(lang.Code) != 2 && len(lang.Code) != 3 || !isASCII(lang.Code) {
+		return Language{}, false
+	}
+
+	base, err := language.ParseBase(lang.Code)
+	if err != nil || base.String() == "und" || len(base.String()) != 2 {
+		return Language{}, false
+	}
+
+	lang.Code = base.String()
+
+	if lang.Region != "" && (len(lang.Region) != 2 || !isASCII(lang.Region)) {
+		return Language{}, false
+	}
+
+	return lang, true
+}
@@ -0,0 +1,67 @@
+package mediarename
+
+import (
+	"testing"
+)
+
+func TestParseSubtitle(t *testing.T) {
+	t.Run("no qualifiers", func(t *testing.T) {
+		stem, sub := ParseSubtitle("src/Show.S01E01.srt")
+
+		RequireEqual(t, "Show.S01E01", stem)
+		RequireEqual(t, Subtitle{}, sub)
+		RequireEqual(t, "", sub.Suffix())
+	})
+
+	t.Run("language and forced", func(t *testing.T) {
+		stem, sub := ParseSubtitle("src/Show.S01E01.en.forced.srt")
+
+		RequireEqual(t, "Show.S01E01", stem)
+		RequireEqual(t, Subtitle{Language: Language{Code: "en"}, Forced: true}, sub)
+		RequireEqual(t, ".en.forced", sub.Suffix())
+	})
+
+	t.Run("region and sdh", func(t *testing.T) {
+		stem, sub := ParseSubtitle("src/Show.S01E01.pt_br.SDH.srt")
+
+		RequireEqual(t, "Show.S01E01", stem)
+		RequireEqual(t, ".pt-BR.sdh", sub.Suffix())
+	})
+
+	t.Run("iso 639-2", func(t *testing.T) {
+		_, sub := ParseSubtitle("src/Show.S01E01.ger.srt")
+
+		RequireEqual(t, ".de", sub.Suffix())
+	})
+
+	t.Run("languages without a known country", func(t *testing.T) {
+		cases := map[string]string{
+			"src/Show.S01E01.id.forced.srt": ".id.forced",
+			"src/Show.S01E01.hr.srt":        ".hr",
+			"src/Show.S01E01.bg.srt":        ".bg",
+			"src/Show.S01E01.hrv.srt":       ".hr",
+			"src/Show.S01E01.ukr.sdh.srt":   ".uk.sdh",
+		}
+
+		for file, suffix := range cases {
+			stem, sub := ParseSubtitle(file)
+
+			RequireEqual(t, "Show.S01E01", stem)
+			RequireEqual(t, suffix, sub.Suffix())
+		}
+	})
+
+	t.Run("three letter code without iso 639-1", func(t *testing.T) {
+		stem, sub := ParseSubtitle("src/Show.S01E01.fil.srt")
+
+		RequireEqual(t, "Show.S01E01.fil", stem)
+		RequireEqual(t, Subtitle{}, sub)
+	})
+
+	t.Run("unknown language", func(t *testing.T) {
+		stem, sub := ParseSubtitle("src/Show.S01E01.xx.srt")
+
+		RequireEqual(t, "Show.S01E01.xx", stem)
+		RequireEqual(t, Subtitle{}, sub)
+	})
+}
@@ -0,0 +1,224 @@
+package mediarename
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// tmdbRateLimit and tmdbRatePeriod keep well under the TMDB API rate limit.
+	tmdbRateLimit  = 20
+	tmdbRatePeriod = time.Second
+)
+
+var (
+	// tmdbPlaceholderRegex matches names TMDB uses for episodes without a translated
+	// name, "Episode 5" in the language of the translation, such as "Folge 5" or "Épisode 5".
+	// The number is checked against the episode separately so titles like "Part 2" are kept.
+	tmdbPlaceholderRegex = regexp.MustCompile(`(?i)^(?:episode|folge|épisode|episodio|episódio|aflevering|afsnit|avsnitt|episodi|jakso|odcinek|epizoda|epizod|epizód|bölüm|эпизод|серия|епізод|серія|επεισόδιο|פרק|حلقة|एपिसोड|에피소드|エピソード|第)\s*(\d+)\s*(?:話|集)?$`)
+)
+
+// TmdbClient provides translated episode names and episode identifiers from The Movie
+// Database. Shows are found using their IMDB ID. It is safe for concurrent use.
+type TmdbClient struct {
+	client  *http.Client
+	baseURL *url.URL
+	apiKey  string
+	limiter *RateLimiter
+	logger  *slog.Logger
+
+	mu      sync.Mutex
+	ids     map[string]int
+	seasons map[string]map[int]string
+}
+
+type tmdbFindResponse struct {
+	TvResults []struct {
+		ID int `json:"id"`
+	} `json:"tv_results"`
+}
+
+type tmdbExternalIDsResponse struct {
+	ID     int    `json:"id"`
+	ImdbID string `json:"imdb_id"`
+	TvdbID int    `json:"tvdb_id"`
+}
+
+type tmdbSeasonResponse struct {
+	Episodes []struct {
+		EpisodeNumber int    `json:"episode_number"`
+		Name          string `json:"name"`
+	} `json:"episodes"`
+}
+
+// NewTmdbClient creates a TmdbClient that authenticates with a TMDB API key.
+func NewTmdbClient(base string, apiKey string, client *http.Client, logger *slog.Logger) (*TmdbClient, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse base URL: %w", err)
+	}
+
+	return &TmdbClient{
+		client:  client,
+		baseURL: u,
+		apiKey:  apiKey,
+		limiter: NewRateLimiter(tmdbRateLimit, tmdbRatePeriod),
+		logger:  logger,
+		ids:     make(map[string]int),
+		seasons: make(map[string]map[int]string),
+	}, nil
+}
+
+// EpisodeName implements the EpisodeTranslator interface. The names of every episode
+// in a season are fetched and cached the first time an episode of it is translated.
+func (c *TmdbClient) EpisodeName(show *Show, episode Episode, lang Language) (string, error) {
+	id, err := c.showID(show)
+	if err != nil || id == 0 {
+		return "", err
+	}
+
+	names, err := c.seasonNames(id, episode.Season, lang)
+	if err != nil {
+		return "", err
+	}
+
+	name := names[episode.Number]
+	if m := tmdbPlaceholderRegex.FindStringSubmatch(name); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil && n == episode.Number {
+			return "", nil
+		}
+	}
+
+	return name, nil
+}
+
+// EpisodeIDs implements the EpisodeIDFinder interface, providing the TMDB, IMDB, and
+// TheTVDB identifiers of an episode.
+func (c *TmdbClient) EpisodeIDs(show *Show, episode Episode) (EpisodeIDs, error) {
+	id, err := c.showID(show)
+	if err != nil || id == 0 {
+		return EpisodeIDs{}, err
+	}
+
+	var res tmdbExternalIDsResponse
+	p := fmt.Sprintf("3/tv/%d/season/%d/episode/%d/external_ids", id, episode.Season, episode.Number)
+	if _, err := c.getJSON(p, url.Values{}, &res); err != nil {
+		return EpisodeIDs{}, fmt.Errorf("unable to lookup TMDB IDs of episode %d of season %d of show %d: %w", episode.Number, episode.Season, id, err)
+	}
+
+	return EpisodeIDs{Imdb: res.ImdbID, TheTvDb: res.TvdbID, Tmdb: res.ID}, nil
+}
+
+// showID returns the TMDB ID of show or zero if it can't be found.
+func (c *TmdbClient) showID(show *Show) (int, error) {
+	imdb := show.Externals.Imdb
+	if imdb == "" {
+		return 0, nil
+	}
+
+	c.mu.Lock()
+	id, ok := c.ids[imdb]
+	c.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	var found tmdbFindResponse
+	params := url.Values{"external_source": {"imdb_id"}}
+	if _, err := c.getJSON("3/find/"+url.PathEscape(imdb), params, &found); err != nil {
+		return 0, fmt.Errorf("unable to lookup TMDB show by imdb ID %s: %w", imdb, err)
+	}
+
+	if len(found.TvResults) > 0 {
+		id = found.TvResults[0].ID
+	} else {
+		c.logger.Debug("show not found on TMDB", "imdb", imdb)
+	}
+
+	c.mu.Lock()
+	c.ids[imdb] = id
+	c.mu.Unlock()
+
+	return id, nil
+}
+
+// seasonNames returns the names of episodes in a season by episode number. Seasons
+// TMDB doesn't have return no names.
+func (c *TmdbClient) seasonNames(id int, season int, lang Language) (map[int]string, error) {
+	key := fmt.Sprintf("%d/%d/%s", id, season, lang)
+	c.mu.Lock()
+	names, ok := c.seasons[key]
+	c.mu.Unlock()
+	if ok {
+		return names, nil
+	}
+
+	var res tmdbSeasonResponse
+	params := url.Values{"language": {lang.String()}}
+	found, err := c.getJSON("3/tv/"+strconv.Itoa(id)+"/season/"+strconv.Itoa(season), params, &res)
+	if err != nil {
+		return nil, fmt.Errorf("unable to lookup TMDB season %d of show %d: %w", season, id, err)
+	}
+
+	names = make(map[int]string, len(res.Episodes))
+	if found {
+		for _, e := range res.Episodes {
+			names[e.EpisodeNumber] = e.Name
+		}
+	}
+
+	c.mu.Lock()
+	c.seasons[key] = names
+	c.mu.Unlock()
+
+	return names, nil
+}
+
+// getJSON makes a request to the API and decodes the response into out. Returns false
+// if nothing was found.
+func (c *TmdbClient) getJSON(path string, params url.Values, out any) (bool, error) {
+	params.Set("api_key", c.apiKey)
+	requestURL := c.baseURL.JoinPath(path)
+	requestURL.RawQuery = params.Encode()
+
+	r, err := http.NewRequest("GET", requestURL.String(), nil)
+	if err != nil {
+		return false, fmt.Errorf("unable to build request: %w", err)
+	}
+
+	r.Header.Set("user-agent", userAgent)
+	c.logger.Debug("making API request", "url", c.baseURL.JoinPath(path))
+	c.limiter.Wait()
+	res, err := c.client.Do(r)
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		_, _ = io.Copy(io.Discard, res.Body)
+		_ = res.Body.Close()
+	}()
+
+	c.logger.Debug("API response", "status", res.Status)
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	if res.StatusCode != 200 {
+		return false, fmt.Errorf("non-success status code %d", res.StatusCode)
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return false, fmt.Errorf("unable to deserialize JSON: %w", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,113 @@
+package mediarename
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestTmdbClient_EpisodeName(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/3/find/tt1234", func(w http.ResponseWriter, r *http.Request) {
+		RequireEqual(t, "secret", r.URL.Query().Get("api_key"))
+		RequireEqual(t, "imdb_id", r.URL.Query().Get("external_source"))
+		_, _ = w.Write([]byte(`{"tv_results": [{"id": 99}]}`))
+	})
+	mux.HandleFunc("/3/tv/99/season/1/episode/1/external_ids", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id": 63056, "imdb_id": "tt5678", "tvdb_id": 456}`))
+	})
+	mux.HandleFunc("/3/tv/99/season/1", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path+"?"+r.URL.Query().Get("language")]++
+		mu.Unlock()
+
+		_, _ = w.Write([]byte(`{"episodes": [{"episode_number": 1, "name": "Der Pilot"}, {"episode_number": 2, "name": "Folge 2"}, {"episode_number": 3, "name": "Teil 2"}, {"episode_number": 4, "name": "Episode 4"}]}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := NewTmdbClient(server.URL, "secret", server.Client(), slog.New(slog.DiscardHandler))
+	RequireNoError(t, err)
+
+	show := testShow
+	show.Externals.Imdb = "tt1234"
+	de := ParseLanguage("de")
+
+	t.Run("translated", func(t *testing.T) {
+		name, err := client.EpisodeName(&show, testEpisodes[0], de)
+		RequireNoError(t, err)
+		RequireEqual(t, "Der Pilot", name)
+	})
+
+	t.Run("placeholder", func(t *testing.T) {
+		name, err := client.EpisodeName(&show, testEpisodes[1], de)
+		RequireNoError(t, err)
+		RequireEqual(t, "", name)
+	})
+
+	t.Run("english placeholder", func(t *testing.T) {
+		name, err := client.EpisodeName(&show, Episode{Season: 1, Number: 4}, de)
+		RequireNoError(t, err)
+		RequireEqual(t, "", name)
+	})
+
+	t.Run("numbered title", func(t *testing.T) {
+		name, err := client.EpisodeName(&show, Episode{Season: 1, Number: 3}, de)
+		RequireNoError(t, err)
+		RequireEqual(t, "Teil 2", name)
+	})
+
+	t.Run("missing episode", func(t *testing.T) {
+		name, err := client.EpisodeName(&show, testEpisodes[2], de)
+		RequireNoError(t, err)
+		RequireEqual(t, "", name)
+	})
+
+	t.Run("missing season", func(t *testing.T) {
+		name, err := client.EpisodeName(&show, Episode{Season: 2, Number: 1}, de)
+		RequireNoError(t, err)
+		RequireEqual(t, "", name)
+	})
+
+	t.Run("no imdb ID", func(t *testing.T) {
+		name, err := client.EpisodeName(&testShow, testEpisodes[0], de)
+		RequireNoError(t, err)
+		RequireEqual(t, "", name)
+	})
+
+	t.Run("episode IDs", func(t *testing.T) {
+		ids, err := client.EpisodeIDs(&show, testEpisodes[0])
+		RequireNoError(t, err)
+		RequireEqual(t, EpisodeIDs{Imdb: "tt5678", TheTvDb: 456, Tmdb: 63056}, ids)
+	})
+
+	t.Run("episode IDs missing", func(t *testing.T) {
+		ids, err := client.EpisodeIDs(&show, testEpisodes[1])
+		RequireNoError(t, err)
+		RequireEqual(t, EpisodeIDs{}, ids)
+	})
+
+	t.Run("season cached", func(t *testing.T) {
+		mu.Lock()
+		defer mu.Unlock()
+		RequireEqual(t, 1, requests["/3/tv/99/season/1?de"])
+	})
+}
+
+func TestTvRenamer_GenerateNamesTranslator(t *testing.T) {
+	client := &fakeClient{show: testShow, episodes: testEpisodes}
+	translator := &fakeTranslatingClient{episodes: map[int]string{1: "Der Pilot"}}
+	opts := TvOptions{Language: ParseLanguage("de"), Translator: translator}
+	renamer := NewTvRenamer(client, opts, slog.New(slog.DiscardHandler))
+
+	renames, err := renamer.GenerateNames(mediaFiles("src/Show.S01E01.mkv", "src/Show.S01E02.mkv"), "dest", "tt1234")
+	RequireNoError(t, err)
+	RequireEqual(t, "dest/the_show/season_01/the_show-s01e01-der_pilot.mkv", renames[0].New)
+	RequireEqual(t, "dest/the_show/season_01/the_show-s01e02-events.mkv", renames[1].New)
+}
@@ -0,0 +1,149 @@
+package mediarename
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+var (
+	ErrRolledBack = errors.New("operations rolled back")
+)
+
+// transaction tracks every change made while renaming a batch of files so that they
+// can all be reversed if any operation fails. It is safe for concurrent use.
+type transaction struct {
+	mu      sync.Mutex
+	done    []JournalEntry
+	dirs    []string
+	backups map[string]string
+	logger  *slog.Logger
+}
+
+func newTransaction(logger *slog.Logger) *transaction {
+	return &transaction{
+		backups: make(map[string]string),
+		logger:  logger,
+	}
+}
+
+// mkdirAll creates dir and any missing parents, recording each directory that didn't
+// already exist so it can be removed during rollback. Returns the created directories.
+// Directories are checked and created while holding the lock so that workers creating
+// the same directory don't both record it.
+func (t *transaction) mkdirAll(dir string, perm os.FileMode) ([]string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	created, err := makeDirs(dir, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parents are before children so they can be removed in reverse order
+	t.dirs = append(t.dirs, created...)
+	return created, nil
+}
+
+// backup moves an existing file at p out of the way so that it can be overwritten and
+// then restored during rollback.
+func (t *transaction) backup(p string) error {
+	backup := p + ".mediarename-backup"
+	if err := os.Rename(p, backup); err != nil {
+		return fmt.Errorf("unable to back up %s before overwriting it: %w", p, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.backups[p] = backup
+	return nil
+}
+
+// stage checks that every file in a batch, including companions, can be operated on before
+// anything is changed: each original must exist and each new name must be unused unless it
+// will be overwritten.
+func stage(renames []Rename) error {
+	var errs []error
+	for _, group := range renames {
+		for _, op := range group.group() {
+			if _, err := os.Lstat(op.Old); err != nil {
+				errs = append(errs, fmt.Errorf("unable to stage %s: %w", op.Old, err))
+			}
+
+			if _, err := os.Lstat(op.New); err == nil && !op.Overwrite && !caseRename(op) {
+				errs = append(errs, fmt.Errorf("unable to stage %s: %w: %s already exists", op.Old, ErrConflict, op.New))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// record adds a completed operation to the transaction.
+func (t *transaction) record(op Rename, mode Mode) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.done = append(t.done, JournalEntry{Old: op.Old, New: op.New, Mode: mode})
+}
+
+// commit discards backups of overwritten files since they will not be restored.
+func (t *transaction) commit() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for p, backup := range t.backups {
+		if err := os.Remove(backup); err != nil {
+			t.logger.Warn("unable to remove backup of overwritten file", "path", p, "backup", backup, "err", err)
+		}
+	}
+}
+
+// rollback reverses every completed operation, most recent first, restores any files
+// that were overwritten, and removes directories that were created. Each step is logged
+// and rollback continues past failures, returning all of them.
+func (t *transaction) rollback() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var errs []error
+	var restored int
+	for i := len(t.done) - 1; i >= 0; i-- {
+		e := t.done[i]
+		if err := undoEntry(e); err != nil {
+			t.logger.Error("unable to roll back", "old", e.Old, "new", e.New, "mode", e.Mode, "err", err)
+			errs = append(errs, err)
+			continue
+		}
+
+		t.logger.Info("rolled back", "old", e.Old, "new", e.New, "mode", e.Mode)
+		restored++
+	}
+
+	for p, backup := range t.backups {
+		if err := os.Rename(backup, p); err != nil {
+			t.logger.Error("unable to restore overwritten file", "path", p, "backup", backup, "err", err)
+			errs = append(errs, err)
+			continue
+		}
+
+		t.logger.Info("restored overwritten file", "path", p)
+	}
+
+	var removed int
+	for i := len(t.dirs) - 1; i >= 0; i-- {
+		// Directories that aren't empty must contain something we didn't put there
+		if err := os.Remove(t.dirs[i]); err != nil {
+			t.logger.Warn("unable to remove created directory", "dir", t.dirs[i], "err", err)
+			continue
+		}
+
+		removed++
+	}
+
+	t.logger.Info("rollback complete", "restored", restored, "overwritten", len(t.backups), "dirs_removed", removed, "errors", len(errs))
+	return errors.Join(errs...)
+}
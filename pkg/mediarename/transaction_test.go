@@ -0,0 +1,108 @@
+package mediarename
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestTvRenamer_RenameFilesAtomic(t *testing.T) {
+	setup := func(t *testing.T) (string, []Rename) {
+		dir := t.TempDir()
+		var renames []Rename
+		for _, name := range []string{"Show.S01E01.mkv", "Show.S01E02.mkv"} {
+			src := filepath.Join(dir, "src", name)
+			writeTestFile(t, src, name)
+			renames = append(renames, Rename{Old: src, New: filepath.Join(dir, "dest", "the_show", "season_01", name)})
+		}
+
+		// The parent of the last file is a regular file so it can't be renamed
+		src := filepath.Join(dir, "src", "Show.S01E03.mkv")
+		writeTestFile(t, src, "Show.S01E03.mkv")
+		writeTestFile(t, filepath.Join(dir, "dest", "blocker"), "")
+		renames = append(renames, Rename{Old: src, New: filepath.Join(dir, "dest", "blocker", "Show.S01E03.mkv")})
+		return dir, renames
+	}
+
+	t.Run("rollback move", func(t *testing.T) {
+		dir, renames := setup(t)
+		state := filepath.Join(dir, "state")
+		opts := TvOptions{Commit: true, Atomic: true, JournalDir: state}
+		renamer := NewTvRenamer(&fakeClient{}, opts, slog.New(slog.DiscardHandler))
+		err := renamer.RenameFiles(renames)
+
+		RequireErrorIs(t, err, ErrRolledBack)
+		for _, op := range renames {
+			RequireEqual(t, filepath.Base(op.Old), readTestFile(t, op.Old))
+		}
+
+		_, err = os.Stat(filepath.Join(dir, "dest", "the_show"))
+		RequireErrorIs(t, err, os.ErrNotExist)
+		_, err = LatestJournal(state)
+		RequireErrorIs(t, err, ErrNoJournal)
+	})
+
+	t.Run("rollback copy with overwrite", func(t *testing.T) {
+		_, renames := setup(t)
+		writeTestFile(t, renames[0].New, "existing")
+		renames[0].Overwrite = true
+
+		opts := TvOptions{Commit: true, Atomic: true, Mode: ModeCopy}
+		renamer := NewTvRenamer(&fakeClient{}, opts, slog.New(slog.DiscardHandler))
+		err := renamer.RenameFiles(renames)
+
+		RequireErrorIs(t, err, ErrRolledBack)
+		RequireEqual(t, "existing", readTestFile(t, renames[0].New))
+		_, err = os.Stat(renames[1].New)
+		RequireErrorIs(t, err, os.ErrNotExist)
+		_, err = os.Stat(renames[0].New + ".mediarename-backup")
+		RequireErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("staging fails before changes", func(t *testing.T) {
+		_, renames := setup(t)
+		renames = renames[:2]
+		renames[1].Old += ".missing"
+
+		renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true, Atomic: true}, slog.New(slog.DiscardHandler))
+		err := renamer.RenameFiles(renames)
+
+		RequireErrorIs(t, err, os.ErrNotExist)
+		RequireEqual(t, "Show.S01E01.mkv", readTestFile(t, renames[0].Old))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		_, renames := setup(t)
+		renames = renames[:2]
+		writeTestFile(t, renames[0].New, "existing")
+		renames[0].Overwrite = true
+
+		renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true, Atomic: true}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.RenameFiles(renames))
+
+		RequireEqual(t, "Show.S01E01.mkv", readTestFile(t, renames[0].New))
+		RequireEqual(t, "Show.S01E02.mkv", readTestFile(t, renames[1].New))
+		_, err := os.Stat(renames[0].New + ".mediarename-backup")
+		RequireErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestTransaction_MkdirAll(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dest", "the_show", "season_01")
+	tx := newTransaction(slog.New(slog.DiscardHandler))
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := tx.mkdirAll(dir, 0755)
+			RequireNoError(t, err)
+		}()
+	}
+
+	wg.Wait()
+	RequireEqual(t, 3, len(tx.dirs))
+}
@@ -1,36 +1,148 @@
 package mediarename
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 )
 
 type Rename struct {
 	Old string
 	New string
+	// Conflict, if set, is why New conflicted with another file when it was generated.
+	Conflict *Conflict
+	// Skip means this file should not be renamed, usually because of a conflict.
+	Skip bool
+	// Overwrite means an existing file at New should be replaced.
+	Overwrite bool
+	// Truncated means episode titles, or the name of an extra, were shortened to make New
+	// fit length limits.
+	Truncated bool
+	// Companions are renames of files that belong to this one, such as subtitles, and
+	// are applied along with it as a unit.
+	Companions []Rename
+	// Show and Episodes are the metadata the new name was generated from.
+	Show     *Show
+	Episodes Episodes
+	// Extra, if set, is the kind of extra the file is instead of an episode.
+	Extra ExtraKind
+}
+
+// group returns op followed by each of its companions that isn't skipped.
+func (op Rename) group() []Rename {
+	group := []Rename{op}
+	for _, c := range op.Companions {
+		if !c.Skip {
+			group = append(group, c)
+		}
+	}
+
+	return group
+}
+
+// TvOptions controls how a TvRenamer generates names and renames files.
+type TvOptions struct {
+	// Commit actually renames files instead of only logging the new names.
+	Commit bool
+	// Language, if set, is the preferred language for show and episode titles. Titles
+	// fall back to the primary title from the provider if there is no translation.
+	Language Language
+	// Translator, if set, provides episode names in Language. Otherwise episode names are
+	// translated by the client if it implements EpisodeTranslator.
+	Translator EpisodeTranslator
+	// EpisodeIDs, if set, provides identifiers of episodes from other providers for NFO
+	// files. Otherwise they are found by the client if it implements EpisodeIDFinder.
+	EpisodeIDs EpisodeIDFinder
+	// Template generates the new name of each file. Defaults to DefaultTemplate.
+	Template *NameTemplate
+	// Sanitizer makes generated names valid on the destination filesystem. Defaults to
+	// DefaultSanitizer.
+	Sanitizer *Sanitizer
+	// Limits are the maximum lengths of generated names. Defaults to DefaultLimits.
+	Limits *Limits
+	// KeepSamples renames files that look like samples instead of excluding them.
+	KeepSamples bool
+	// SampleRatio is the fraction of the median size of videos below which a video is
+	// excluded as a sample. Defaults to DefaultSampleRatio, a negative ratio only
+	// excludes samples by name and directory.
+	SampleRatio float64
+	// Extras places files without an episode number that look like featurettes,
+	// deleted scenes, or trailers in folders for them instead of skipping them.
+	Extras bool
+	// Mode is how files are placed at their new names. Defaults to ModeMove.
+	Mode Mode
+	// Verify compares the size and checksum of copied files to the originals.
+	Verify bool
+	// Preserve is the attributes of originals to keep on copies, including files copied
+	// by a move between filesystems. Defaults to DefaultPreserve.
+	Preserve *Preserve
+	// DirMode, if set, is the exact permissions of directories created in the destination.
+	// Otherwise directories are created with 0755 less the umask.
+	DirMode os.FileMode
+	// Owner, if set, is the user and group to give files placed in the destination and
+	// directories created for them. Hard linked files keep the owner of the original since
+	// they share it.
+	Owner *Owner
+	// Fallback, if set, is the mode to use when the requested mode is not possible
+	// because the source and destination are on different filesystems or the filesystem
+	// doesn't support it. Only ModeCopy is supported, so the originals are kept. If not
+	// set, these files fail with ErrCrossDevice or ErrNoReflink instead.
+	Fallback Mode
+	// RelativeLinks creates symbolic links with targets relative to the link instead of
+	// absolute targets.
+	RelativeLinks bool
+	// OnConflict decides what happens when multiple files would have the same new name
+	// or a file with the new name already exists. Defaults to ConflictSkip.
+	OnConflict ConflictPolicy
+	// Atomic reverses every completed operation if any operation fails, so that either
+	// all files are renamed or none are.
+	Atomic bool
+	// JournalDir, if set, is the directory where a journal of every committed operation
+	// is written so that it can be undone later.
+	JournalDir string
+	// Workers is the number of files to copy in parallel, including files moved between
+	// filesystems. Defaults to 1.
+	Workers int
+	// BandwidthLimit, if set, is the maximum number of bytes per second to write when
+	// copying files, shared between all workers.
+	BandwidthLimit int64
+	// Progress, if set, receives a progress display while copying files.
+	Progress io.Writer
 }
 
 type TvRenamer struct {
-	client MediaClient
-	commit bool
-	logger *slog.Logger
+	client  MediaClient
+	opts    TvOptions
+	journal *Journal
+	tx      *transaction
+	logger  *slog.Logger
 }
 
-func NewTvRenamer(client MediaClient, commit bool, logger *slog.Logger) *TvRenamer {
+func NewTvRenamer(client MediaClient, opts TvOptions, logger *slog.Logger) *TvRenamer {
 	return &TvRenamer{
 		client: client,
-		commit: commit,
+		opts:   opts,
 		logger: logger,
 	}
 }
 
-func (r *TvRenamer) FindFiles(base string, extensions map[string]struct{}) ([]string, error) {
-	var out []string
+// FindFiles finds files under base with one of the given extensions, along with their
+// companion files, grouped by GroupFiles. Companions without a video are logged and
+// not renamed. Unless keeping samples, files that look like samples of videos, by
+// their name, a "Sample" directory, or being much smaller than other videos, are logged
+// and returned separately instead of being renamed.
+func (r *TvRenamer) FindFiles(base string, extensions map[string]struct{}) ([]MediaFile, []Sample, error) {
+	var found []string
+	sizes := make(map[string]int64)
 
 	err := filepath.Walk(base, func(p string, info fs.FileInfo, err error) error {
 		if err != nil {
@@ -42,21 +154,47 @@ func (r *TvRenamer) FindFiles(base string, extensions map[string]struct{}) ([]st
 		}
 
 		ext := path.Ext(p)
-		if _, ok := extensions[ext]; ok {
-			out = append(out, p)
+		if _, ok := extensions[ext]; ok || isCompanion(p) {
+			found = append(found, p)
+			sizes[p] = info.Size()
 		}
 
 		return nil
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("unable to find files: %w", err)
+		return nil, nil, fmt.Errorf("unable to find files: %w", err)
+	}
+
+	var samples []Sample
+	if !r.opts.KeepSamples {
+		found, samples = splitSamples(base, found)
+	}
+
+	out, orphans := GroupFiles(found)
+	for _, orphan := range orphans {
+		r.logger.Warn("companion file has no matching video", "file", orphan)
+	}
+
+	if !r.opts.KeepSamples {
+		ratio := r.opts.SampleRatio
+		if ratio == 0 {
+			ratio = DefaultSampleRatio
+		}
+
+		var small []Sample
+		out, small = splitSmallSamples(out, sizes, ratio, r.opts.Extras)
+		samples = append(samples, small...)
 	}
 
-	return out, nil
+	for _, s := range samples {
+		r.logger.Info("excluded sample", "file", s.Path, "reason", s.Reason)
+	}
+
+	return out, samples, nil
 }
 
-func (r *TvRenamer) GenerateNames(files []string, dest string, imdb ImdbID) ([]Rename, error) {
+func (r *TvRenamer) GenerateNames(files []MediaFile, dest string, imdb ImdbID) ([]Rename, error) {
 	show, err := r.client.ShowByImdb(imdb)
 	if err != nil {
 		return nil, fmt.Errorf("show lookup error for imdb ID %s: %w", imdb, err)
@@ -67,72 +205,406 @@ func (r *TvRenamer) GenerateNames(files []string, dest string, imdb ImdbID) ([]R
 		return nil, fmt.Errorf("episode lookup error show %s (%d): %w", show.Name, show.ID, err)
 	}
 
+	if !r.opts.Language.IsZero() {
+		show, episodes = r.localize(show, episodes)
+	}
+
 	lookup := NewEpisodeLookup(episodes, r.logger)
+	absolute := absoluteNumbers(episodes)
 	out := make([]Rename, 0, len(episodes))
 
 	for _, file := range files {
-		matched, err := lookup.FindEpisodes(file)
+		matched, err := lookup.FindEpisodes(file.Path)
+		if kind, ok := ClassifyExtra(file.Path); ok && r.opts.Extras && errors.Is(err, ErrBadMetadata) {
+			op, err := r.extraRename(file, kind, dest, show)
+			if err != nil {
+				r.logger.Warn("unable to generate new name for extra", "file", file.Path, "kind", kind, "err", err)
+				continue
+			}
+
+			out = append(out, op)
+			continue
+		}
+
 		if err != nil {
-			r.logger.Warn("unable to generate new name for file", "file", file, "err", err)
+			r.logger.Warn("unable to generate new name for file", "file", file.Path, "companions", len(file.Companions), "err", err)
 			continue
 		}
 
-		newName := r.nameFromEpisodes(file, dest, show, matched)
-		out = append(out, Rename{
-			Old: file,
-			New: newName,
-		})
+		newName, truncated, err := r.nameFromEpisodes(file.Path, file.Companions, dest, show, matched, absolute)
+		if err != nil {
+			r.logger.Warn("unable to generate new name for file", "file", file.Path, "companions", len(file.Companions), "err", err)
+			continue
+		}
+
+		if truncated {
+			r.logger.Warn("shortened episode title to fit length limits", "file", file.Path, "new", newName)
+		}
+
+		op := Rename{
+			Old:       file.Path,
+			New:       newName,
+			Truncated: truncated,
+			Show:      show,
+			Episodes:  matched,
+		}
+
+		for _, companion := range file.Companions {
+			op.Companions = append(op.Companions, Rename{Old: companion})
+		}
+
+		out = append(out, op)
 	}
 
-	return out, nil
+	return r.resolveConflicts(out, dest)
 }
 
-func (r *TvRenamer) nameFromEpisodes(file string, dest string, show *Show, episodes Episodes) string {
-	ext := path.Ext(file)
+// localize returns copies of the show and episodes with names replaced by translations
+// in the configured language, when the client or translator supports them. Names without a translation
+// are left as-is.
+func (r *TvRenamer) localize(show *Show, episodes Episodes) (*Show, Episodes) {
+	lang := r.opts.Language
+	localShow := *show
+	localEpisodes := make(Episodes, len(episodes))
+	copy(localEpisodes, episodes)
+
+	if t, ok := r.client.(ShowTranslator); ok {
+		name, err := t.ShowName(show, lang)
+		if err != nil {
+			r.logger.Warn("unable to translate show name", "show", show.Name, "lang", lang, "err", err)
+		} else if name != "" {
+			localShow.Name = name
+		} else {
+			r.logger.Debug("no translation for show name", "show", show.Name, "lang", lang)
+		}
+	}
+
+	translator := r.opts.Translator
+	if translator == nil {
+		translator, _ = r.client.(EpisodeTranslator)
+	}
+
+	if t := translator; t != nil {
+		for i, e := range localEpisodes {
+			name, err := t.EpisodeName(show, e, lang)
+			if err != nil {
+				r.logger.Warn("unable to translate episode name", "episode", e.Name, "lang", lang, "err", err)
+			} else if name != "" {
+				localEpisodes[i].Name = name
+			}
+		}
+	} else {
+		r.logger.Debug("metadata provider does not translate episode names", "lang", lang)
+	}
+
+	return &localShow, localEpisodes
+}
 
+func (r *TvRenamer) nameFromEpisodes(file string, companions []string, dest string, show *Show, episodes Episodes, absolute map[int]int) (string, bool, error) {
 	// If there are multiple episodes that match for this particular file (such as when a
 	// finale is two episodes but aired at the same time and thus the same file): use the first
 	// match to generate the file name but append each episode after that with "-eXX" after
 	// the primary tag.
 	first := episodes[0]
-	episodes = episodes[1:]
 	tag := strings.Builder{}
 	tag.WriteString(fmt.Sprintf("s%02de%02d", first.Season, first.Number))
 
-	for _, e := range episodes {
+	for _, e := range episodes[1:] {
 		tag.WriteString(fmt.Sprintf("-e%02d", e.Number))
 	}
 
-	newFile := fmt.Sprintf(
-		"%s-%s-%s%s",
-		sanitize(show.Name),
-		tag.String(),
-		sanitize(first.Name),
-		ext,
-	)
+	data := NameData{
+		Show:     show.Name,
+		Year:     premiereYear(show.Premiered),
+		Season:   first.Season,
+		Episode:  first.Number,
+		Tag:      tag.String(),
+		Title:    first.Name,
+		Absolute: absolute[first.ID],
+		Airdate:  first.Airdate,
+		Release:  ParseRelease(file),
+		Ext:      path.Ext(file),
+	}
+
+	if IsSubtitle(file) {
+		_, sub := ParseSubtitle(file)
+		data.Ext = sub.Suffix() + path.Ext(file)
+	}
+
+	data.IDs.TvMaze = show.ID
+	data.IDs.TheTvDb = show.Externals.TheTvDb
+	data.IDs.TvRage = show.Externals.TvRage
+	data.IDs.Imdb = show.Externals.Imdb
 
-	return path.Join(
-		dest,
-		sanitize(show.Name),
-		fmt.Sprintf("season_%02d", first.Season),
-		newFile,
-	)
+	for _, e := range episodes {
+		data.Episodes = append(data.Episodes, e.Number)
+		data.Titles = append(data.Titles, e.Name)
+	}
+
+	tmpl := r.opts.Template
+	if tmpl == nil {
+		tmpl = defaultTemplate
+	}
+
+	san := DefaultSanitizer()
+	if r.opts.Sanitizer != nil {
+		san = *r.opts.Sanitizer
+	}
+
+	return tmpl.ExecuteLimited(dest, data, san, r.limits(file, data.Ext, companions))
+}
+
+// limits returns the configured limits for the new name of file, ending in ext, with
+// room reserved for the longest of its companions.
+func (r *TvRenamer) limits(file string, ext string, companions []string) Limits {
+	limits := DefaultLimits()
+	if r.opts.Limits != nil {
+		limits = *r.opts.Limits
+	}
+
+	// Companions replace the extension of the file, leave room for the longest of them
+	for _, companion := range companions {
+		limits.Reserve = max(limits.Reserve, len(companionRest(file, companion))-len(ext))
+	}
+
+	return limits
 }
 
 func (r *TvRenamer) RenameFiles(renames []Rename) error {
+	mode := r.opts.Mode
+	if mode == "" {
+		mode = ModeMove
+	}
+
+	if r.opts.Fallback != "" && !slices.Contains(FallbackModes(), string(r.opts.Fallback)) {
+		return fmt.Errorf("unsupported fallback mode %s", r.opts.Fallback)
+	}
+
+	renames = slices.DeleteFunc(slices.Clone(renames), func(op Rename) bool {
+		if op.Skip {
+			r.logger.Info("skip", "old", op.Old, "new", op.New)
+		}
+
+		return op.Skip
+	})
+
+	if !r.opts.Commit {
+		r.warnCrossDevice(mode, renames)
+
+		for _, op := range renames {
+			for _, member := range op.group() {
+				r.logOp(mode, member)
+			}
+		}
+
+		return nil
+	}
+
+	if r.opts.JournalDir != "" {
+		journal, err := NewJournal(r.opts.JournalDir)
+		if err != nil {
+			return err
+		}
+
+		r.logger.Info("writing journal", "path", journal.Path())
+		r.journal = journal
+		defer func() {
+			_ = journal.Close()
+			r.journal = nil
+		}()
+	}
+
+	if !r.opts.Atomic {
+		return r.applyAll(mode, renames)
+	}
+
+	if err := stage(renames); err != nil {
+		return err
+	}
+
+	r.tx = newTransaction(r.logger)
+	defer func() { r.tx = nil }()
+
+	if err := r.applyAll(mode, renames); err != nil {
+		r.logger.Warn("rolling back completed operations", "err", err)
+		if rbErr := r.tx.rollback(); rbErr != nil {
+			return fmt.Errorf("%w, rollback incomplete: %w", err, rbErr)
+		}
+
+		// Everything in the journal was reversed so there's nothing left to undo
+		if r.journal != nil {
+			_ = r.journal.Close()
+			_ = os.Remove(r.journal.Path())
+		}
+
+		return fmt.Errorf("%w: %w", ErrRolledBack, err)
+	}
+
+	r.tx.commit()
+	return nil
+}
+
+// applyAll applies mode to each rename, sequentially for modes that only rename or link
+// files and in parallel for modes that copy file contents.
+func (r *TvRenamer) applyAll(mode Mode, renames []Rename) error {
+	opts := copyOptions{verify: r.opts.Verify, preserve: r.opts.Preserve}
+	if r.opts.BandwidthLimit > 0 {
+		opts.limiter = NewRateLimiter(r.opts.BandwidthLimit, time.Second)
+	}
+
+	switch mode {
+	case ModeMove, ModeMoveSymlink:
+		// Moves between filesystems copy file contents so they're done in parallel too,
+		// after the moves within a filesystem are done in order.
+		local, remote := r.splitDevices(renames)
+		for _, op := range local {
+			if err := r.applyGroup(mode, op, opts); err != nil {
+				return err
+			}
+		}
+
+		if len(remote) == 0 {
+			return nil
+		}
+
+		return r.copyFiles(mode, remote, opts)
+	case ModeHardlink, ModeSymlink:
+		for _, op := range renames {
+			if err := r.applyGroup(mode, op, opts); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case ModeCopy, ModeReflink:
+		return r.copyFiles(mode, renames, opts)
+	default:
+		return fmt.Errorf("unsupported mode %s", mode)
+	}
+}
+
+// splitDevices splits renames into those placing files on the same filesystem they are
+// already on and those placing files on a different filesystem.
+func (r *TvRenamer) splitDevices(renames []Rename) ([]Rename, []Rename) {
+	var local, remote []Rename
 	for _, op := range renames {
-		r.logger.Info("rename", "old", op.Old, "new", op.New)
+		if sameDevice(op.Old, path.Dir(op.New)) {
+			local = append(local, op)
+		} else {
+			remote = append(remote, op)
+		}
+	}
 
-		if r.commit {
-			dir := path.Dir(op.New)
-			err := os.MkdirAll(dir, 0755)
-			if err != nil {
-				return fmt.Errorf("unable to create parent directory %s: %w", dir, err)
+	return local, remote
+}
+
+// copyFiles applies mode to each rename using a pool of workers, displaying progress
+// if enabled. The first error encountered stops any further operations from starting.
+func (r *TvRenamer) copyFiles(mode Mode, renames []Rename, opts copyOptions) error {
+	var totalFiles, totalBytes int64
+	for _, op := range renames {
+		for _, member := range op.group() {
+			totalFiles++
+			if info, err := os.Stat(member.Old); err == nil {
+				totalBytes += info.Size()
 			}
+		}
+	}
 
-			err = os.Rename(op.Old, op.New)
-			if err != nil {
-				return fmt.Errorf("unable to rename %s to %s: %w", op.Old, op.New, err)
+	var progress *Progress
+	if r.opts.Progress != nil {
+		progress = NewProgress(r.opts.Progress, totalFiles, totalBytes)
+		opts.progress = progress
+		progress.Start()
+		defer progress.Stop()
+	}
+
+	workers := max(r.opts.Workers, 1)
+	ops := make(chan Rename)
+	errs := make(chan error, len(renames))
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for op := range ops {
+				if err := r.applyGroup(mode, op, opts); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(ops)
+		for _, op := range renames {
+			select {
+			case ops <- op:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// Stop handing out work after the first error but let in-progress operations finish
+	var first error
+	go func() {
+		wg.Wait()
+		close(errs)
+	}()
+
+	for err := range errs {
+		if first == nil {
+			first = err
+			close(done)
+		}
+	}
+
+	return first
+}
+
+// applyGroup applies mode to a rename and each of its companions as a unit. If any of
+// them fail, the others are reversed: by the transaction for the whole batch when atomic,
+// otherwise by a transaction for just the group. Operations are journaled once the whole
+// group succeeds.
+func (r *TvRenamer) applyGroup(mode Mode, op Rename, opts copyOptions) error {
+	tx := r.tx
+	if tx == nil && len(op.Companions) > 0 {
+		tx = newTransaction(r.logger)
+	}
+
+	group := op.group()
+	modes := make([]Mode, 0, len(group))
+	for _, member := range group {
+		r.logOp(mode, member)
+		used, err := r.applyOp(tx, mode, member, opts)
+		if err != nil {
+			if tx != nil && tx != r.tx {
+				r.logger.Warn("rolling back file and companions", "old", op.Old, "err", err)
+				if rbErr := tx.rollback(); rbErr != nil {
+					return fmt.Errorf("%w, rollback incomplete: %w", err, rbErr)
+				}
+			}
+
+			return err
+		}
+
+		modes = append(modes, used)
+		if opts.progress != nil {
+			opts.progress.FileDone()
+		}
+	}
+
+	if tx != nil && tx != r.tx {
+		tx.commit()
+	}
+
+	if r.journal != nil {
+		for i, member := range group {
+			if err := r.journal.Record(member, modes[i]); err != nil {
+				return err
 			}
 		}
 	}
@@ -140,13 +612,221 @@ func (r *TvRenamer) RenameFiles(renames []Rename) error {
 	return nil
 }
 
-func sanitize(val string) string {
-	val = strings.ReplaceAll(val, " ", "_")
-	val = strings.ReplaceAll(val, "/", "_")
-	val = strings.ReplaceAll(val, ":", "")
-	val = strings.ReplaceAll(val, "'", "")
-	val = strings.ReplaceAll(val, ",", "")
-	val = strings.ReplaceAll(val, "&", "and")
-	val = strings.ToLower(val)
-	return val
+// applyOp creates the parent directory of the new name of op and places the file
+// there according to mode, recording it in tx if set. Returns the mode actually used,
+// which is the fallback mode if mode wasn't possible.
+func (r *TvRenamer) applyOp(tx *transaction, mode Mode, op Rename, opts copyOptions) (Mode, error) {
+	dir := path.Dir(op.New)
+	if err := r.mkdirAll(tx, dir); err != nil {
+		return "", fmt.Errorf("unable to create parent directory %s: %w", dir, err)
+	}
+
+	if caseRename(op) {
+		// Only renaming can change the case of a name on a case-insensitive filesystem,
+		// anything else would place the file on top of itself.
+		if mode != ModeMove && mode != ModeMoveSymlink {
+			return "", fmt.Errorf("%w: %s is the same file as %s", ErrConflict, op.New, op.Old)
+		}
+	} else if _, err := os.Lstat(op.New); err == nil {
+		if !op.Overwrite {
+			return "", fmt.Errorf("%w: %s already exists", ErrConflict, op.New)
+		}
+
+		// Links can't replace existing files, everything else replaces them atomically.
+		// Within a transaction, the existing file is kept so that it can be restored.
+		if tx != nil {
+			if err := tx.backup(op.New); err != nil {
+				return "", err
+			}
+		} else if mode == ModeHardlink || mode == ModeSymlink {
+			if err := os.Remove(op.New); err != nil {
+				return "", fmt.Errorf("unable to remove %s to overwrite it: %w", op.New, err)
+			}
+		}
+	}
+
+	var err error
+	switch mode {
+	case ModeCopy:
+		err = copyFile(op.Old, op.New, opts)
+		if err != nil {
+			return "", fmt.Errorf("unable to copy %s to %s: %w", op.Old, op.New, err)
+		}
+	case ModeHardlink:
+		err = linkFile(op.Old, op.New)
+		if errors.Is(err, ErrCrossDevice) && r.opts.Fallback != "" {
+			r.logger.Warn("unable to hard link across devices, using fallback", "fallback", r.opts.Fallback, "old", op.Old, "new", op.New)
+			return r.applyOp(tx, r.opts.Fallback, op, opts)
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("unable to hard link %s to %s: %w", op.Old, op.New, err)
+		}
+	case ModeReflink:
+		err = reflinkFile(op.Old, op.New, opts)
+		if errors.Is(err, ErrNoReflink) && r.opts.Fallback != "" {
+			r.logger.Warn("unable to reflink, using fallback", "fallback", r.opts.Fallback, "old", op.Old, "new", op.New, "err", err)
+			return r.applyOp(tx, r.opts.Fallback, op, opts)
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("unable to reflink %s to %s: %w", op.Old, op.New, err)
+		}
+	case ModeSymlink:
+		err = symlinkFile(op.Old, op.New, r.opts.RelativeLinks)
+		if err != nil {
+			return "", fmt.Errorf("unable to symlink %s to %s: %w", op.Old, op.New, err)
+		}
+	case ModeMoveSymlink:
+		err = moveAndSymlinkFile(op.Old, op.New, r.opts.RelativeLinks, opts)
+		if err != nil {
+			return "", fmt.Errorf("unable to rename and symlink %s to %s: %w", op.Old, op.New, err)
+		}
+	default:
+		err = moveFile(op.Old, op.New, opts)
+		if err != nil {
+			return "", fmt.Errorf("unable to rename %s to %s: %w", op.Old, op.New, err)
+		}
+	}
+
+	if tx != nil {
+		tx.record(op, mode)
+	}
+
+	// Changing the owner of a hard link would change the original too
+	if mode != ModeHardlink {
+		if err := r.chown(op.New); err != nil {
+			return "", err
+		}
+	}
+
+	return mode, nil
+}
+
+// mkdirAll creates dir and any missing parents, recording them in tx if set, and gives
+// each created directory the configured permissions and owner.
+func (r *TvRenamer) mkdirAll(tx *transaction, dir string) error {
+	var created []string
+	var err error
+	if tx != nil {
+		created, err = tx.mkdirAll(dir, 0755)
+	} else {
+		created, err = makeDirs(dir, 0755)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, d := range created {
+		if r.opts.DirMode != 0 {
+			if err := os.Chmod(d, r.opts.DirMode); err != nil {
+				return fmt.Errorf("unable to set permissions of %s: %w", d, err)
+			}
+		}
+
+		if err := r.chown(d); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// chown gives p the configured owner, if any. Symbolic links are changed rather than
+// their targets.
+func (r *TvRenamer) chown(p string) error {
+	if r.opts.Owner == nil {
+		return nil
+	}
+
+	if err := os.Lchown(p, r.opts.Owner.UID, r.opts.Owner.GID); err != nil {
+		return fmt.Errorf("unable to change owner of %s: %w", p, err)
+	}
+
+	return nil
+}
+
+// writeFile atomically writes contents to p, creating any missing parent directories,
+// and gives it the configured owner.
+func (r *TvRenamer) writeFile(p string, contents []byte) error {
+	dir := path.Dir(p)
+	if err := r.mkdirAll(nil, dir); err != nil {
+		return fmt.Errorf("unable to create parent directory %s: %w", dir, err)
+	}
+
+	if err := writeFile(p, contents); err != nil {
+		return err
+	}
+
+	return r.chown(p)
+}
+
+func (r *TvRenamer) logOp(mode Mode, op Rename) {
+	msg := "rename"
+	if mode != ModeMove {
+		msg = string(mode)
+	}
+
+	if op.Truncated {
+		r.logger.Info(msg, "old", op.Old, "new", op.New, "truncated", true)
+		return
+	}
+
+	r.logger.Info(msg, "old", op.Old, "new", op.New)
+}
+
+// RemoveDanglingLinks removes symbolic links under base whose targets no longer exist,
+// such as links created by ModeSymlink to files that have since been moved or deleted.
+// Links are only logged, not removed, unless committing.
+func (r *TvRenamer) RemoveDanglingLinks(base string) error {
+	links, err := danglingLinks(base)
+	if err != nil {
+		return fmt.Errorf("unable to find dangling links: %w", err)
+	}
+
+	for _, link := range links {
+		r.logger.Info("remove dangling link", "link", link)
+
+		if r.opts.Commit {
+			if err := os.Remove(link); err != nil {
+				return fmt.Errorf("unable to remove dangling link %s: %w", link, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// warnCrossDevice logs a warning for each destination directory that is on a different
+// filesystem than the files being placed into it, when that changes how mode behaves.
+func (r *TvRenamer) warnCrossDevice(mode Mode, renames []Rename) {
+	var msg string
+	switch {
+	case mode == ModeMove || mode == ModeMoveSymlink:
+		msg = "destination is on a different device, files will be copied then removed"
+	case mode == ModeHardlink && r.opts.Fallback == "":
+		msg = "destination is on a different device, hard links will fail"
+	case mode == ModeHardlink:
+		msg = fmt.Sprintf("destination is on a different device, hard links will fall back to %s", r.opts.Fallback)
+	case mode == ModeReflink && r.opts.Fallback == "":
+		msg = "destination is on a different device, reflinks will fail"
+	case mode == ModeReflink:
+		msg = fmt.Sprintf("destination is on a different device, reflinks will fall back to %s", r.opts.Fallback)
+	default:
+		return
+	}
+
+	seen := make(map[string]struct{})
+	for _, op := range renames {
+		dir := path.Dir(op.New)
+		if _, ok := seen[dir]; ok {
+			continue
+		}
+
+		seen[dir] = struct{}{}
+		if !sameDevice(op.Old, dir) {
+			r.logger.Warn(msg, "src", path.Dir(op.Old), "dest", dir)
+		}
+	}
 }
@@ -0,0 +1,318 @@
+package mediarename
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testShow = Show{
+	ID:   1,
+	URL:  "https://api.example.com/show/1",
+	Name: "The Show",
+}
+
+type fakeClient struct {
+	show     Show
+	episodes Episodes
+}
+
+func (c *fakeClient) ShowByImdb(ImdbID) (*Show, error) {
+	show := c.show
+	return &show, nil
+}
+
+func (c *fakeClient) Episodes(*Show) (Episodes, error) {
+	return c.episodes, nil
+}
+
+type fakeTranslatingClient struct {
+	fakeClient
+	shows    map[string]string
+	episodes map[int]string
+}
+
+func (c *fakeTranslatingClient) ShowName(_ *Show, lang Language) (string, error) {
+	return c.shows[lang.Code], nil
+}
+
+func (c *fakeTranslatingClient) EpisodeName(_ *Show, episode Episode, lang Language) (string, error) {
+	if lang.Code != "de" {
+		return "", nil
+	}
+
+	return c.episodes[episode.ID], nil
+}
+
+// mediaFiles groups paths with their companions, ignoring orphans.
+func mediaFiles(paths ...string) []MediaFile {
+	files, _ := GroupFiles(paths)
+	return files
+}
+
+func TestTvRenamer_GenerateNames(t *testing.T) {
+	t.Run("default language", func(t *testing.T) {
+		client := &fakeClient{show: testShow, episodes: testEpisodes}
+		renamer := NewTvRenamer(client, TvOptions{}, slog.New(slog.DiscardHandler))
+		renames, err := renamer.GenerateNames(mediaFiles("src/Show.S01E01.mkv"), "dest", "tt1234")
+
+		RequireNoError(t, err)
+		RequireEqual(t, 1, len(renames))
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e01-pilot.mkv", renames[0].New)
+	})
+
+	t.Run("translated show and episode", func(t *testing.T) {
+		client := &fakeTranslatingClient{
+			fakeClient: fakeClient{show: testShow, episodes: testEpisodes},
+			shows:      map[string]string{"de": "Die Sendung"},
+			episodes:   map[int]string{1: "Der Anfang"},
+		}
+		renamer := NewTvRenamer(client, TvOptions{Language: ParseLanguage("de")}, slog.New(slog.DiscardHandler))
+		renames, err := renamer.GenerateNames(mediaFiles("src/Show.S01E01.mkv", "src/Show.S01E02.mkv"), "dest", "tt1234")
+
+		RequireNoError(t, err)
+		RequireEqual(t, 2, len(renames))
+		RequireEqual(t, "dest/die_sendung/season_01/die_sendung-s01e01-der_anfang.mkv", renames[0].New)
+		RequireEqual(t, "dest/die_sendung/season_01/die_sendung-s01e02-events.mkv", renames[1].New)
+	})
+
+	t.Run("missing translation falls back", func(t *testing.T) {
+		client := &fakeTranslatingClient{
+			fakeClient: fakeClient{show: testShow, episodes: testEpisodes},
+			shows:      map[string]string{"de": "Die Sendung"},
+		}
+		renamer := NewTvRenamer(client, TvOptions{Language: ParseLanguage("fr")}, slog.New(slog.DiscardHandler))
+		renames, err := renamer.GenerateNames(mediaFiles("src/Show.S01E01.mkv"), "dest", "tt1234")
+
+		RequireNoError(t, err)
+		RequireEqual(t, 1, len(renames))
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e01-pilot.mkv", renames[0].New)
+	})
+
+	t.Run("room for companions", func(t *testing.T) {
+		client := &fakeClient{show: testShow, episodes: testEpisodes}
+		renamer := NewTvRenamer(client, TvOptions{Limits: &Limits{MaxName: 33}}, slog.New(slog.DiscardHandler))
+		renames, err := renamer.GenerateNames(mediaFiles("src/Show.S01E01.mkv", "src/Show.S01E01.pt-BR.sdh.srt"), "dest", "tt1234")
+
+		RequireNoError(t, err)
+		RequireEqual(t, 1, len(renames))
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e01-pil.mkv", renames[0].New)
+		RequireEqual(t, "dest/the_show/season_01/the_show-s01e01-pil.pt-BR.sdh.srt", renames[0].Companions[0].New)
+		RequireEqual(t, true, renames[0].Truncated)
+	})
+}
+
+func TestTvRenamer_RenameFiles(t *testing.T) {
+	t.Run("dry run", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src", "Show.S01E01.mkv")
+		dst := filepath.Join(dir, "dest", "the_show", "season_01", "the_show-s01e01-pilot.mkv")
+		writeTestFile(t, src, "episode 1")
+
+		renamer := NewTvRenamer(&fakeClient{}, TvOptions{}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.RenameFiles([]Rename{{Old: src, New: dst}}))
+
+		_, err := os.Stat(dst)
+		RequireErrorIs(t, err, os.ErrNotExist)
+		RequireEqual(t, "episode 1", readTestFile(t, src))
+	})
+
+	t.Run("move", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src", "Show.S01E01.mkv")
+		dst := filepath.Join(dir, "dest", "the_show", "season_01", "the_show-s01e01-pilot.mkv")
+		writeTestFile(t, src, "episode 1")
+
+		renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.RenameFiles([]Rename{{Old: src, New: dst}}))
+
+		_, err := os.Stat(src)
+		RequireErrorIs(t, err, os.ErrNotExist)
+		RequireEqual(t, "episode 1", readTestFile(t, dst))
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		dir := t.TempDir()
+		var renames []Rename
+		for i := 1; i <= 5; i++ {
+			src := filepath.Join(dir, "src", fmt.Sprintf("Show.S01E%02d.mkv", i))
+			dst := filepath.Join(dir, "dest", "the_show", "season_01", fmt.Sprintf("the_show-s01e%02d.mkv", i))
+			writeTestFile(t, src, fmt.Sprintf("episode %d", i))
+			renames = append(renames, Rename{Old: src, New: dst})
+		}
+
+		var progress bytes.Buffer
+		opts := TvOptions{Commit: true, Mode: ModeCopy, Verify: true, Workers: 3, Progress: &progress}
+		renamer := NewTvRenamer(&fakeClient{}, opts, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.RenameFiles(renames))
+
+		for i, op := range renames {
+			expected := fmt.Sprintf("episode %d", i+1)
+			RequireEqual(t, expected, readTestFile(t, op.Old))
+			RequireEqual(t, expected, readTestFile(t, op.New))
+		}
+
+		RequireEqual(t, true, strings.Contains(progress.String(), "5/5 files, 45 B/45 B"))
+	})
+
+	t.Run("copy missing source", func(t *testing.T) {
+		dir := t.TempDir()
+		renames := []Rename{{Old: filepath.Join(dir, "missing.mkv"), New: filepath.Join(dir, "dest", "new.mkv")}}
+
+		renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true, Mode: ModeCopy, Workers: 2}, slog.New(slog.DiscardHandler))
+		err := renamer.RenameFiles(renames)
+
+		RequireErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("hardlink", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src", "Show.S01E01.mkv")
+		dst := filepath.Join(dir, "dest", "the_show", "season_01", "the_show-s01e01-pilot.mkv")
+		writeTestFile(t, src, "episode 1")
+
+		renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true, Mode: ModeHardlink}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.RenameFiles([]Rename{{Old: src, New: dst}}))
+
+		srcInfo, err := os.Stat(src)
+		RequireNoError(t, err)
+		dstInfo, err := os.Stat(dst)
+		RequireNoError(t, err)
+		RequireEqual(t, true, os.SameFile(srcInfo, dstInfo))
+	})
+
+	t.Run("hardlink cross device", func(t *testing.T) {
+		src := filepath.Join(t.TempDir(), "src", "Show.S01E01.mkv")
+		writeTestFile(t, src, "episode 1")
+
+		other, err := os.MkdirTemp("/dev/shm", "mediarename")
+		if err != nil {
+			t.Skip("no tmpfs available for cross device test")
+		}
+
+		t.Cleanup(func() { _ = os.RemoveAll(other) })
+		if sameDevice(src, other) {
+			t.Skip("no second filesystem available for cross device test")
+		}
+
+		dst := filepath.Join(other, "the_show", "season_01", "the_show-s01e01-pilot.mkv")
+		renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true, Mode: ModeHardlink}, slog.New(slog.DiscardHandler))
+		RequireErrorIs(t, renamer.RenameFiles([]Rename{{Old: src, New: dst}}), ErrCrossDevice)
+
+		renamer = NewTvRenamer(&fakeClient{}, TvOptions{Commit: true, Mode: ModeHardlink, Fallback: ModeCopy}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.RenameFiles([]Rename{{Old: src, New: dst}}))
+		RequireEqual(t, "episode 1", readTestFile(t, src))
+		RequireEqual(t, "episode 1", readTestFile(t, dst))
+	})
+
+	t.Run("symlink relative", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src", "Show.S01E01.mkv")
+		dst := filepath.Join(dir, "dest", "the_show", "season_01", "the_show-s01e01-pilot.mkv")
+		writeTestFile(t, src, "episode 1")
+
+		renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true, Mode: ModeSymlink, RelativeLinks: true}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.RenameFiles([]Rename{{Old: src, New: dst}}))
+
+		target, err := os.Readlink(dst)
+		RequireNoError(t, err)
+		RequireEqual(t, "../../../src/Show.S01E01.mkv", target)
+		RequireEqual(t, "episode 1", readTestFile(t, dst))
+	})
+
+	t.Run("symlink absolute", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src", "Show.S01E01.mkv")
+		dst := filepath.Join(dir, "dest", "the_show", "season_01", "the_show-s01e01-pilot.mkv")
+		writeTestFile(t, src, "episode 1")
+
+		renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true, Mode: ModeSymlink}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.RenameFiles([]Rename{{Old: src, New: dst}}))
+
+		target, err := os.Readlink(dst)
+		RequireNoError(t, err)
+		RequireEqual(t, src, target)
+	})
+
+	t.Run("move and symlink", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src", "Show.S01E01.mkv")
+		dst := filepath.Join(dir, "dest", "the_show", "season_01", "the_show-s01e01-pilot.mkv")
+		writeTestFile(t, src, "episode 1")
+
+		renamer := NewTvRenamer(&fakeClient{}, TvOptions{Commit: true, Mode: ModeMoveSymlink}, slog.New(slog.DiscardHandler))
+		RequireNoError(t, renamer.RenameFiles([]Rename{{Old: src, New: dst}}))
+
+		target, err := os.Readlink(src)
+		RequireNoError(t, err)
+		RequireEqual(t, dst, target)
+
+		info, err := os.Lstat(dst)
+		RequireNoError(t, err)
+		RequireEqual(t, true, info.Mode().IsRegular())
+	})
+}
+
+func TestTvRenamer_RenameFilesReflinkFallback(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src", "Show.S01E01.mkv")
+	dst := filepath.Join(dir, "dest", "the_show", "season_01", "the_show-s01e01-pilot.mkv")
+	writeTestFile(t, src, "episode 1")
+
+	// Whether or not the filesystem supports reflinks, the result should be an
+	// independent file with the same contents as the original.
+	opts := TvOptions{Commit: true, Mode: ModeReflink, Fallback: ModeCopy}
+	renamer := NewTvRenamer(&fakeClient{}, opts, slog.New(slog.DiscardHandler))
+	RequireNoError(t, renamer.RenameFiles([]Rename{{Old: src, New: dst}}))
+
+	srcInfo, err := os.Stat(src)
+	RequireNoError(t, err)
+	dstInfo, err := os.Stat(dst)
+	RequireNoError(t, err)
+	RequireEqual(t, false, os.SameFile(srcInfo, dstInfo))
+	RequireEqual(t, "episode 1", readTestFile(t, dst))
+}
+
+func TestTvRenamer_RenameFilesMoveFallback(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src", "Show.S01E01.mkv")
+	dst := filepath.Join(dir, "dest", "the_show", "season_01", "the_show-s01e01-pilot.mkv")
+	writeTestFile(t, src, "episode 1")
+
+	// Moving would remove the originals that reflinks are meant to keep
+	opts := TvOptions{Commit: true, Mode: ModeReflink, Fallback: ModeMove}
+	renamer := NewTvRenamer(&fakeClient{}, opts, slog.New(slog.DiscardHandler))
+	err := renamer.RenameFiles([]Rename{{Old: src, New: dst}})
+
+	RequireEqual(t, true, err != nil)
+	RequireEqual(t, "episode 1", readTestFile(t, src))
+}
+
+func TestTvRenamer_RemoveDanglingLinks(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src", "Show.S01E01.mkv")
+	dest := filepath.Join(dir, "dest")
+	good := filepath.Join(dest, "the_show", "season_01", "the_show-s01e01-pilot.mkv")
+	dangling := filepath.Join(dest, "the_show", "season_01", "the_show-s01e02-events.mkv")
+
+	writeTestFile(t, src, "episode 1")
+	RequireNoError(t, os.MkdirAll(filepath.Dir(good), 0755))
+	RequireNoError(t, os.Symlink(src, good))
+	RequireNoError(t, os.Symlink(filepath.Join(dir, "src", "missing.mkv"), dangling))
+
+	renamer := NewTvRenamer(&fakeClient{}, TvOptions{}, slog.New(slog.DiscardHandler))
+	RequireNoError(t, renamer.RemoveDanglingLinks(dest))
+	_, err := os.Lstat(dangling)
+	RequireNoError(t, err)
+
+	renamer = NewTvRenamer(&fakeClient{}, TvOptions{Commit: true}, slog.New(slog.DiscardHandler))
+	RequireNoError(t, renamer.RemoveDanglingLinks(dest))
+	_, err = os.Lstat(dangling)
+	RequireErrorIs(t, err, os.ErrNotExist)
+	_, err = os.Lstat(good)
+	RequireNoError(t, err)
+}